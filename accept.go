@@ -0,0 +1,141 @@
+package httpu
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptRange is a single entry of an Accept style header, such as "text/*;q=0.5"
+type acceptRange struct {
+	value string
+	q     float64
+}
+
+// parseAccept parses the comma separated entries of an Accept, or Accept-Encoding, header.
+// Entries with an invalid q-value are skipped. Values are lower cased.
+func parseAccept(header string) []acceptRange {
+	ranges := make([]acceptRange, 0, 4)
+
+	for _, entry := range strings.Split(header, ",") {
+		params := strings.Split(entry, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		valid := true
+
+		for _, param := range params[1:] {
+			name, paramValue := splitParam(param)
+
+			if name != "q" {
+				continue
+			}
+
+			parsed, err := strconv.ParseFloat(paramValue, 64)
+
+			if err != nil || parsed < 0 || parsed > 1 {
+				valid = false
+				break
+			}
+
+			q = parsed
+		}
+
+		if valid {
+			ranges = append(ranges, acceptRange{value: value, q: q})
+		}
+	}
+
+	return ranges
+}
+
+// splitParam splits a "name=value" header parameter, lower casing the name
+func splitParam(param string) (string, string) {
+	index := strings.Index(param, "=")
+
+	if index < 0 {
+		return strings.ToLower(strings.TrimSpace(param)), ""
+	}
+
+	return strings.ToLower(strings.TrimSpace(param[:index])), strings.TrimSpace(param[index+1:])
+}
+
+// mediaRangeQuality returns the q-value the accept ranges give to mediaType. The most specific
+// matching range is used, with an exact match taking precedence over "type/*", which takes
+// precedence over "*/*". false is returned if no range matches mediaType.
+func mediaRangeQuality(ranges []acceptRange, mediaType string) (float64, bool) {
+	mainType := mediaType
+	if index := strings.Index(mediaType, "/"); index >= 0 {
+		mainType = mediaType[:index]
+	}
+
+	bestSpecificity := 0
+	bestQ := 0.0
+
+	for _, r := range ranges {
+		specificity := 0
+
+		switch r.value {
+		case mediaType:
+			specificity = 3
+		case mainType + "/*":
+			specificity = 2
+		case "*/*":
+			specificity = 1
+		}
+
+		if specificity > bestSpecificity {
+			bestSpecificity = specificity
+			bestQ = r.q
+		}
+	}
+
+	return bestQ, bestSpecificity > 0
+}
+
+// negotiate returns the index of the offered media type most preferred by the Accept header.
+// Ties are broken by the order of the offers. If the header is empty the first offer is
+// chosen. -1 is returned if none of the offers are acceptable.
+func negotiate(header string, offers []string) int {
+	if strings.TrimSpace(header) == "" {
+		if len(offers) == 0 {
+			return -1
+		}
+
+		return 0
+	}
+
+	ranges := parseAccept(header)
+	best := -1
+	bestQ := 0.0
+
+	for index, offer := range offers {
+		q, matched := mediaRangeQuality(ranges, offer)
+
+		if matched && q > bestQ {
+			best = index
+			bestQ = q
+		}
+	}
+
+	return best
+}
+
+// addVary adds value to the Vary header of h if it is not already present
+func addVary(h http.Header, value string) {
+	for _, vary := range h.Values("Vary") {
+		for _, existing := range strings.Split(vary, ",") {
+			existing = strings.TrimSpace(existing)
+
+			if existing == "*" || strings.EqualFold(existing, value) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", value)
+}
@@ -0,0 +1,170 @@
+package httpu
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrBodyTooLarge is returned when a request body is larger than the limit set by
+	// WithMaxBodyBytes.
+	ErrBodyTooLarge = errors.New("httpu: request body too large")
+
+	// ErrUnsupportedEncoding is returned when a request body has a Content-Encoding with no
+	// Decompressor registered for it.
+	ErrUnsupportedEncoding = errors.New("httpu: unsupported content encoding")
+)
+
+// Decompressor returns a reader that decompresses the data read from r with a content
+// coding, such as gzip. Closing the reader must release its resources, but must not close r.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+// decompressors maps content codings to their Decompressor
+type decompressors map[string]Decompressor
+
+// defaultDecompressors returns the Decompressors registered by default
+func defaultDecompressors() decompressors {
+	return decompressors{
+		"gzip":    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		"deflate": func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) },
+	}
+}
+
+// codings returns the sorted content codings that have a Decompressor registered
+func (d decompressors) codings() []string {
+	codings := make([]string, 0, len(d))
+
+	for coding := range d {
+		codings = append(codings, coding)
+	}
+
+	sort.Strings(codings)
+	return codings
+}
+
+// requestBody is the body of a request with its content codings removed. Closing it closes
+// each Decompressor and then the body of the request.
+type requestBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (rb *requestBody) Close() error {
+	var err error
+
+	for index := len(rb.closers) - 1; index >= 0; index-- {
+		if closeErr := rb.closers[index].Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// requestBody returns the body of the request decompressed with each of the codings of its
+// Content-Encoding, and limited to limit bytes once decompressed. A limit of 0 or less does
+// not limit the body. If there is an error the status code to write for it is returned: a
+// HTTP 413 if the Content-Length of an uncompressed body exceeds the limit, a HTTP 415 if a
+// coding has no Decompressor, along with an Accept-Encoding header listing the supported
+// codings, and a HTTP 400 if the compressed body is invalid.
+func (i *impl) requestBody(limit int64) (io.ReadCloser, int, error) {
+	var codings []string
+
+	for _, value := range i.r.Header.Values("Content-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+
+			if coding != "" && coding != "identity" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+
+	if len(codings) == 0 && limit > 0 && i.r.ContentLength > limit {
+		return nil, http.StatusRequestEntityTooLarge, ErrBodyTooLarge
+	}
+
+	body := &requestBody{Reader: i.r.Body, closers: []io.Closer{i.r.Body}}
+
+	// codings are listed in the order they were applied, so they are removed in reverse
+	for index := len(codings) - 1; index >= 0; index-- {
+		decompressor, hasDecompressor := i.o.decompressors[codings[index]]
+
+		if hasDecompressor == false {
+			i.w.Header().Set("Accept-Encoding", strings.Join(i.o.decompressors.codings(), ", "))
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("%w: %v", ErrUnsupportedEncoding, codings[index])
+		}
+
+		reader, err := decompressor(body.Reader)
+
+		if err != nil {
+			body.Close()
+			return nil, http.StatusBadRequest, err
+		}
+
+		body.Reader = reader
+		body.closers = append(body.closers, reader)
+	}
+
+	body.Reader = newMaxBytesReader(body.Reader, limit)
+	return body, 0, nil
+}
+
+// maxBytesReader works like the reader returned from http.MaxBytesReader, except that
+// limitErr is returned once more than n bytes have been read from r.
+type maxBytesReader struct {
+	r        io.Reader
+	n        int64
+	err      error
+	limitErr error
+}
+
+// newMaxBytesReader returns r limited to n bytes, returning ErrBodyTooLarge if the limit is
+// exceeded. If n is 0 or less r is returned as is
+func newMaxBytesReader(r io.Reader, n int64) io.Reader {
+	return newLimitReader(r, n, ErrBodyTooLarge)
+}
+
+// newLimitReader returns r limited to n bytes, returning limitErr if the limit is exceeded.
+// If n is 0 or less r is returned as is
+func newLimitReader(r io.Reader, n int64, limitErr error) io.Reader {
+	if n <= 0 {
+		return r
+	}
+
+	return &maxBytesReader{r: r, n: n, limitErr: limitErr}
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// read one byte past the limit so a body of exactly n bytes is not an error
+	if int64(len(p)) > m.n+1 {
+		p = p[:m.n+1]
+	}
+
+	n, err := m.r.Read(p)
+
+	if int64(n) <= m.n {
+		m.n -= int64(n)
+		m.err = err
+		return n, err
+	}
+
+	n = int(m.n)
+	m.n = 0
+	m.err = m.limitErr
+	return n, m.err
+}
@@ -0,0 +1,200 @@
+package httpu_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clavoie/httpu"
+)
+
+// gzipString returns s compressed with gzip
+func gzipString(t *testing.T, s string) string {
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+
+	if _, err := io.WriteString(zw, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+// deflateString returns s compressed with zlib, the deflate content coding
+func deflateString(t *testing.T, s string) string {
+	buf := new(bytes.Buffer)
+	zw := zlib.NewWriter(buf)
+
+	if _, err := io.WriteString(zw, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestDecompressRequest(t *testing.T) {
+	type Json struct {
+		Field string
+	}
+
+	body := `{"Field":"value"}`
+
+	t.Run("Success", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			encoding string
+			body     string
+		}{
+			{"Gzip", "gzip", gzipString(t, body)},
+			{"Deflate", "Deflate", deflateString(t, body)},
+			{"Identity", "identity", body},
+			{"Chain", "deflate, gzip", gzipString(t, deflateString(t, body))},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				r, w, _, i, finish := newTestImpl(t, test.body, httpu.WithMaxBodyBytes(int64(len(body))))
+				defer finish()
+
+				r.Header.Set("Content-Encoding", test.encoding)
+				j := new(Json)
+
+				if i.DecodeJsonOr400(j, "decode") {
+					t.Fatal("Was not expecting an error", w.Code)
+				}
+
+				if j.Field != "value" {
+					t.Fatal("Unexpected decode", j.Field)
+				}
+			})
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		r, w, l, i, finish := newTestImpl(t, body)
+		defer finish()
+
+		r.Header.Set("Content-Encoding", "br")
+		l.EXPECT().Warningf(NonEmptyStr(), NonEmptyStr())
+
+		if i.DecodeOr400(new(Json), "decode") == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusUnsupportedMediaType || w.Header().Get("Accept-Encoding") != "deflate, gzip" {
+			t.Fatal("Unexpected response", w.Code, w.Header())
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		r, w, l, i, finish := newTestImpl(t, body)
+		defer finish()
+
+		r.Header.Set("Content-Encoding", "gzip")
+		l.EXPECT().Warningf(NonEmptyStr(), NonEmptyStr())
+
+		if i.DecodeJsonOr400(new(Json), "decode") == false || w.Code != http.StatusBadRequest {
+			t.Fatal("Unexpected response", w.Code)
+		}
+	})
+
+	t.Run("DecompressedLimit", func(t *testing.T) {
+		large := `{"Field":"` + strings.Repeat("a", 100000) + `"}`
+		r, w, l, i, finish := newTestImpl(t, gzipString(t, large), httpu.WithMaxBodyBytes(1000))
+		defer finish()
+
+		r.Header.Set("Content-Encoding", "gzip")
+		l.EXPECT().Warningf(NonEmptyStr(), httpu.ErrBodyTooLarge)
+
+		if r.ContentLength > 1000 {
+			t.Fatal("Was expecting the compressed body to be within the limit", r.ContentLength)
+		}
+
+		if i.DecodeJsonOr400(new(Json), "decode") == false || w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatal("Unexpected response", w.Code)
+		}
+	})
+
+	t.Run("WithDecompressor", func(t *testing.T) {
+		lower := func(r io.Reader) (io.ReadCloser, error) {
+			data, err := io.ReadAll(r)
+			return io.NopCloser(bytes.NewReader(bytes.ToLower(data))), err
+		}
+
+		r, w, _, i, finish := newTestImpl(t, `{"Field":"VALUE"}`, httpu.WithDecompressor("LOWER", lower))
+		defer finish()
+
+		r.Header.Set("Content-Encoding", "lower")
+		j := new(struct {
+			Field string `json:"field"`
+		})
+
+		if i.DecodeJsonOr400(j, "decode") || j.Field != "value" {
+			t.Fatal("Unexpected decode", w.Code, j.Field)
+		}
+	})
+
+	t.Run("RemovedDecompressor", func(t *testing.T) {
+		r, w, l, i, finish := newTestImpl(t, gzipString(t, body), httpu.WithDecompressor("gzip", nil))
+		defer finish()
+
+		r.Header.Set("Content-Encoding", "gzip")
+		l.EXPECT().Warningf(NonEmptyStr(), NonEmptyStr())
+
+		if i.DecodeJsonOr400(new(Json), "decode") == false || w.Code != http.StatusUnsupportedMediaType {
+			t.Fatal("Unexpected response", w.Code)
+		}
+	})
+
+	t.Run("Multipart", func(t *testing.T) {
+		for _, streaming := range []bool{false, true} {
+			file := multipartFile{field: "data", filename: "data.json", content: body}
+			r, w, _, i, finish := newMultipartImpl(t, []multipartFile{file}, nil, httpu.WithMultipartStreaming(streaming))
+
+			compressed, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r.Body = io.NopCloser(strings.NewReader(gzipString(t, string(compressed))))
+			r.Header.Set("Content-Encoding", "gzip")
+			j := new(Json)
+
+			if i.TryDecodeJsonFile("data", j) || j.Field != "value" {
+				t.Fatal("Unexpected decode", streaming, w.Code, j.Field)
+			}
+
+			finish()
+		}
+	})
+
+	t.Run("MultipartUnsupported", func(t *testing.T) {
+		file := multipartFile{field: "data", filename: "data.json", content: body}
+		r, w, l, i, finish := newMultipartImpl(t, []multipartFile{file}, nil)
+		defer finish()
+
+		r.Header.Set("Content-Encoding", "br")
+		l.EXPECT().Warningf(NonEmptyStr(), "data", NonEmptyStr()).Do(func(format string, args ...interface{}) {
+			if errors.Is(args[1].(error), httpu.ErrUnsupportedEncoding) == false {
+				t.Fatal("Unexpected error", args[1])
+			}
+		})
+
+		if i.TryDecodeJsonFile("data", new(Json)) == false || w.Code != http.StatusUnsupportedMediaType {
+			t.Fatal("Unexpected response", w.Code)
+		}
+	})
+}
@@ -0,0 +1,303 @@
+package httpu
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultCompressionMinSize is the size of a response below which it is not compressed
+const defaultCompressionMinSize = 1024
+
+// Compressor returns a writer that compresses everything written to it with a content
+// coding, such as gzip, and writes the result to w. Closing the writer must flush any
+// remaining compressed data to w, but must not close w.
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// compressorEntry is a content coding and the Compressor registered for it
+type compressorEntry struct {
+	coding     string
+	compressor Compressor
+}
+
+// compressors are the registered Compressors, in order of preference
+type compressors []compressorEntry
+
+// defaultCompressors returns the Compressors registered by default
+func defaultCompressors() compressors {
+	return compressors{
+		{"gzip", func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }},
+		{"deflate", func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil }},
+	}
+}
+
+// negotiate returns the compressor entry the Accept-Encoding header prefers, or nil if the
+// header accepts none of them. Among codings with the same q-value the earliest is used.
+func (c compressors) negotiate(header string) *compressorEntry {
+	if header == "" {
+		return nil
+	}
+
+	ranges := parseAccept(header)
+	var best *compressorEntry
+	bestQ := 0.0
+
+	for index := range c {
+		q, exact := 0.0, false
+
+		for _, r := range ranges {
+			if r.value == c[index].coding {
+				q, exact = r.q, true
+			} else if r.value == "*" && exact == false {
+				q = r.q
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = &c[index], q
+		}
+	}
+
+	return best
+}
+
+// isCompressedType returns true if responses of the content type are already compressed, and
+// gain nothing from being compressed again
+func isCompressedType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "font/woff"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+		"application/x-bzip2", "application/x-xz", "application/x-7z-compressed",
+		"application/x-rar-compressed", "application/vnd.rar":
+		return true
+	}
+
+	return false
+}
+
+// compressWriter is a http.ResponseWriter that compresses the response with the content
+// coding negotiated from the request. The first minSize bytes of the response are held until
+// it is known whether the response is large enough to be worth compressing. The
+// compressWriter must be closed once the response is written.
+type compressWriter struct {
+	http.ResponseWriter
+	entry      *compressorEntry
+	minSize    int
+	buf        []byte
+	decided    bool
+	statusCode int
+	zw         io.WriteCloser
+	err        error
+}
+
+// newCompressWriter returns a compressWriter for the response to r. Vary: Accept-Encoding is
+// added to the response, as its body now depends on the header.
+func newCompressWriter(w http.ResponseWriter, r *http.Request, o *options) *compressWriter {
+	addVary(w.Header(), "Accept-Encoding")
+
+	var entry *compressorEntry
+	if r != nil && r.Method != http.MethodHead {
+		entry = o.compressors.negotiate(r.Header.Get("Accept-Encoding"))
+	}
+
+	minSize := o.compressionMinSize
+	if minSize < 0 {
+		minSize = 0
+	}
+
+	return &compressWriter{ResponseWriter: w, entry: entry, minSize: minSize}
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for use by http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.statusCode != 0 {
+		return
+	}
+
+	cw.statusCode = statusCode
+
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		cw.statusCode = 0
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	if cw.compressible() == false {
+		cw.decide(false)
+		return
+	}
+
+	length, err := strconv.Atoi(cw.Header().Get("Content-Length"))
+	if err == nil && length < cw.minSize {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.statusCode == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.decided == false {
+		if len(cw.buf)+len(p) < cw.minSize {
+			cw.buf = append(cw.buf, p...)
+			return len(p), nil
+		}
+
+		cw.decide(true)
+
+		if cw.err != nil {
+			return 0, cw.err
+		}
+	}
+
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush compresses the response, if it can be, writes everything held so far, and flushes
+// the underlying http.ResponseWriter if it is an http.Flusher
+func (cw *compressWriter) Flush() {
+	if cw.statusCode == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.decided == false {
+		cw.decide(true)
+	}
+
+	if flusher, isFlusher := cw.zw.(interface{ Flush() error }); isFlusher && cw.err == nil {
+		cw.err = flusher.Flush()
+	}
+
+	if flusher, isFlusher := cw.ResponseWriter.(http.Flusher); isFlusher {
+		flusher.Flush()
+	}
+}
+
+// Close writes any of the response still held, and the end of the compressed data. A
+// response that never reached minSize is written uncompressed.
+func (cw *compressWriter) Close() error {
+	if cw.statusCode != 0 && cw.decided == false {
+		cw.decide(false)
+	}
+
+	if cw.zw != nil {
+		err := cw.zw.Close()
+		cw.zw = nil
+
+		if cw.err == nil {
+			cw.err = err
+		}
+	}
+
+	return cw.err
+}
+
+// compressible returns true if a coding was negotiated for the response, and it is of a
+// status and type worth compressing
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+
+	switch {
+	case cw.entry == nil,
+		cw.statusCode < 200, cw.statusCode == http.StatusNoContent,
+		cw.statusCode == http.StatusPartialContent, cw.statusCode == http.StatusNotModified,
+		header.Get("Content-Encoding") != "", header.Get("Content-Range") != "",
+		isCompressedType(header.Get("Content-Type")):
+		return false
+	}
+
+	return true
+}
+
+// decide writes the header of the response, compressing the response if compress is true
+// and the response is compressible, and then writes the held part of the response. If the
+// Compressor fails the response is written uncompressed.
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+
+	if compress && cw.compressible() {
+		zw, err := cw.entry.compressor(cw.ResponseWriter)
+
+		if err == nil {
+			cw.zw = zw
+		}
+	}
+
+	if cw.zw != nil {
+		header := cw.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.entry.coding)
+		weakenETag(header)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+
+	if len(cw.buf) == 0 {
+		return
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if cw.zw != nil {
+		_, cw.err = cw.zw.Write(buf)
+	} else {
+		_, cw.err = cw.ResponseWriter.Write(buf)
+	}
+}
+
+// weakenNotModified weakens the ETag of a HTTP 304 if a content coding was negotiated, so that
+// it carries the same validator as the compressed response it stands in for
+func (cw *compressWriter) weakenNotModified() {
+	if cw.entry != nil {
+		weakenETag(cw.Header())
+	}
+}
+
+// weakenETag makes a strong ETag in header weak
+func weakenETag(header http.Header) {
+	if etag := header.Get("ETag"); etag != "" && strings.HasPrefix(etag, "W/") == false {
+		header.Set("ETag", "W/"+etag)
+	}
+}
+
+// Compress returns an http.Handler that compresses the responses written by next with the
+// content coding the Accept-Encoding header of the request prefers, gzip and deflate by
+// default. opts can change the minimum size of compressed responses and the Compressors
+// registered:
+//
+//	http.Handle("/reports", httpu.Compress(reports, httpu.WithCompressionMinSize(4096)))
+//
+// Responses smaller than the minimum size, already compressed types such as images and zip
+// files, partial content, and responses that set their own Content-Encoding are written as
+// is. Vary: Accept-Encoding is added to every response. The Content-Length of compressed
+// responses is removed, and strong ETags are made weak.
+func Compress(next http.Handler, opts ...Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := newCompressWriter(w, r, newOptions(opts...))
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
@@ -0,0 +1,222 @@
+package httpu_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/clavoie/httpu"
+)
+
+// upperCompressor is a test Compressor that upper cases the response
+type upperCompressor struct {
+	w io.Writer
+}
+
+func (uc *upperCompressor) Write(p []byte) (int, error) {
+	return uc.w.Write(bytes.ToUpper(p))
+}
+
+func (uc *upperCompressor) Close() error {
+	return nil
+}
+
+// decompress returns the body of the response decoded with its Content-Encoding
+func decompress(t *testing.T, w *httptest.ResponseRecorder) string {
+	var r io.Reader = w.Body
+	var err error
+
+	switch w.Header().Get("Content-Encoding") {
+	case "gzip":
+		r, err = gzip.NewReader(r)
+	case "deflate":
+		r, err = zlib.NewReader(r)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("compress me ", 200)
+
+	serve := func(method, acceptEncoding string, handler http.HandlerFunc, opts ...httpu.Option) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://test.com/test", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		httpu.Compress(handler, opts...).ServeHTTP(w, r)
+
+		return w
+	}
+
+	write := func(contentType, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Header().Set("ETag", `"v1"`)
+			io.WriteString(w, body)
+		}
+	}
+
+	t.Run("Negotiation", func(t *testing.T) {
+		tests := []struct {
+			acceptEncoding string
+			expected       string
+		}{
+			{"gzip, deflate", "gzip"},
+			{"deflate, gzip", "gzip"},
+			{"gzip;q=0.5, deflate", "deflate"},
+			{"*;q=0.1", "gzip"},
+			{"gzip;q=0, *", "deflate"},
+			{"gzip;q=0, deflate;q=0", ""},
+			{"identity", ""},
+			{"", ""},
+		}
+
+		for _, test := range tests {
+			w := serve("GET", test.acceptEncoding, write("text/plain", large))
+
+			if actual := w.Header().Get("Content-Encoding"); actual != test.expected {
+				t.Fatal("Unexpected coding", test.acceptEncoding, actual)
+			}
+
+			if w.Header().Get("Vary") != "Accept-Encoding" || decompress(t, w) != large {
+				t.Fatal("Unexpected response", test.acceptEncoding, w.Header())
+			}
+		}
+	})
+
+	t.Run("Compressed", func(t *testing.T) {
+		w := serve("GET", "gzip", write("application/json", large))
+
+		if w.Header().Get("Content-Length") != "" || w.Header().Get("ETag") != `W/"v1"` {
+			t.Fatal("Unexpected headers", w.Header())
+		}
+
+		if w.Body.Len() >= len(large) {
+			t.Fatal("Was expecting the response to be compressed", w.Body.Len())
+		}
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			method  string
+			handler http.HandlerFunc
+		}{
+			{"MinSize", "GET", write("text/plain", "small")},
+			{"Image", "GET", write("image/png", large)},
+			{"Head", "HEAD", write("text/plain", large)},
+			{"Encoded", "GET", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "br")
+				io.WriteString(w, large)
+			}},
+			{"NoContent", "GET", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				w := serve(test.method, "gzip", test.handler)
+
+				if encoding := w.Header().Get("Content-Encoding"); encoding == "gzip" {
+					t.Fatal("Was not expecting the response to be compressed")
+				}
+
+				if length := w.Header().Get("Content-Length"); length != "" && length != strconv.Itoa(w.Body.Len()) && test.method != "HEAD" {
+					t.Fatal("Unexpected content length", length, w.Body.Len())
+				}
+			})
+		}
+	})
+
+	t.Run("MinSizeOption", func(t *testing.T) {
+		w := serve("GET", "gzip", write("text/plain", "small"), httpu.WithCompressionMinSize(0))
+
+		if w.Header().Get("Content-Encoding") != "gzip" || decompress(t, w) != "small" {
+			t.Fatal("Was expecting the response to be compressed")
+		}
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		w := serve("GET", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "first")
+			w.(http.Flusher).Flush()
+			io.WriteString(w, "second")
+		})
+
+		if w.Header().Get("Content-Encoding") != "gzip" || w.Flushed == false || decompress(t, w) != "firstsecond" {
+			t.Fatal("Unexpected response", w.Header(), w.Flushed)
+		}
+	})
+
+	t.Run("WithCompressor", func(t *testing.T) {
+		upper := func(w io.Writer) (io.WriteCloser, error) { return &upperCompressor{w}, nil }
+		opts := []httpu.Option{httpu.WithCompressor("upper", upper), httpu.WithCompressor("deflate", nil)}
+
+		w := serve("GET", "gzip, upper, deflate", write("text/plain", large), opts...)
+		if w.Header().Get("Content-Encoding") != "upper" || w.Body.String() != strings.ToUpper(large) {
+			t.Fatal("Was expecting the new compressor to be preferred", w.Header())
+		}
+
+		w = serve("GET", "deflate", write("text/plain", large), opts...)
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Fatal("Was expecting deflate to be removed", w.Header())
+		}
+	})
+}
+
+func TestWithCompression(t *testing.T) {
+	type Json struct {
+		Field string
+	}
+
+	tests := []struct {
+		name     string
+		field    string
+		expected string
+	}{
+		{"Large", strings.Repeat("a", 4096), "gzip"},
+		{"Small", "a", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, w, _, i, finish := newTestImpl(t, "", httpu.WithCompression(true))
+			defer finish()
+
+			r.Header.Set("Accept-Encoding", "gzip")
+
+			if i.EncodeJsonOr500(&Json{test.field}, "encode") {
+				t.Fatal("Was not expecting an error")
+			}
+
+			if w.Header().Get("Content-Encoding") != test.expected || w.Header().Get("Vary") != "Accept-Encoding" {
+				t.Fatal("Unexpected headers", w.Header())
+			}
+
+			if test.expected == "" && w.Header().Get("Content-Length") != strconv.Itoa(w.Body.Len()) {
+				t.Fatal("Unexpected content length", w.Header().Get("Content-Length"))
+			}
+
+			if strings.Contains(decompress(t, w), test.field) == false {
+				t.Fatal("Unexpected body")
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package httpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// ErrTooManyItems is returned when a streamed request body has more items than the limit set
+// by WithMaxItems.
+var ErrTooManyItems = errors.New("httpu: too many items in request body")
+
+// ItemErr is returned when an item of a streamed request body cannot be decoded or handled
+type ItemErr struct {
+	// Index is the index of the item, starting at 0
+	Index int
+
+	// Err is the error decoding or handling the item
+	Err error
+}
+
+func (ie *ItemErr) Error() string {
+	return fmt.Sprintf("httpu: item %v: %v", ie.Index, ie.Err)
+}
+
+func (ie *ItemErr) Unwrap() error {
+	return ie.Err
+}
+
+// DecodeEach decodes each item of the request body into a new T and calls fn with it, using
+// Impl.DecodeEachOr400. The body is read one item at a time, so that large bodies are never
+// held in memory as a whole:
+//
+//	failed := httpu.DecodeEach(i, func(index int, widget *Widget) error {
+//		return store.Insert(ctx, widget)
+//	}, "Could not import widgets")
+func DecodeEach[T any](i Impl, fn func(index int, item *T) error, format string, args ...interface{}) bool {
+	newItem := func() interface{} { return new(T) }
+
+	return i.DecodeEachOr400(newItem, func(index int, item interface{}) error {
+		return fn(index, item.(*T))
+	}, format, args...)
+}
+
+// isNdjson returns true if the media type is one of those used for newline delimited json
+func isNdjson(mediaType string) bool {
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return true
+	}
+
+	return false
+}
+
+func (i *impl) DecodeEachOr400(newItem func() interface{}, fn func(index int, item interface{}) error, format string, args ...interface{}) bool {
+	defer i.r.Body.Close()
+
+	body, statusCode, err := i.requestBody(i.o.maxBodyBytes)
+
+	if i.WriteIfErr(err, statusCode, format, args...) {
+		return true
+	}
+
+	defer body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(i.r.Header.Get("Content-Type"))
+	ndjson := isNdjson(mediaType)
+	decoder := json.NewDecoder(body)
+
+	if ndjson == false {
+		err = expectDelim(decoder, '[')
+
+		if i.decodeEachErr(err, "request body is not a json array", format, args...) {
+			return true
+		}
+	}
+
+	for index := 0; decoder.More(); index++ {
+		if i.o.maxItems > 0 && index >= i.o.maxItems {
+			return i.WriteIfErr(ErrTooManyItems, http.StatusRequestEntityTooLarge, format, args...)
+		}
+
+		item := newItem()
+		err = i.decodeItem(decoder, item)
+
+		if err != nil {
+			detail := fmt.Sprintf("item %v could not be decoded", index)
+
+			var strictErr *StrictJsonErr
+			if errors.As(err, &strictErr) {
+				detail = fmt.Sprintf("item %v: %v", index, strictErr)
+			}
+
+			return i.decodeEachErr(&ItemErr{Index: index, Err: err}, detail, format, args...)
+		}
+
+		err = fn(index, item)
+
+		if err != nil {
+			return i.WriteErr(&ItemErr{Index: index, Err: err}, format, args...)
+		}
+	}
+
+	if ndjson == false {
+		err = expectDelim(decoder, ']')
+
+		if i.decodeEachErr(err, "request body is not a json array", format, args...) {
+			return true
+		}
+	}
+
+	_, err = decoder.Token()
+
+	if err != io.EOF {
+		return i.decodeEachErr(errors.New("httpu: unexpected data after json value"), "unexpected data after the items", format, args...)
+	}
+
+	return false
+}
+
+// decodeItem decodes the next item of a streamed request body into item. With strict json
+// decoding the item is checked for unknown fields and duplicate keys the same way
+// DecodeJsonOr400 checks a whole body, with offsets given from the start of the body.
+func (i *impl) decodeItem(decoder *json.Decoder, item interface{}) error {
+	if i.o.strictJson == false {
+		return decoder.Decode(item)
+	}
+
+	var raw json.RawMessage
+	err := decoder.Decode(&raw)
+
+	if err != nil {
+		return err
+	}
+
+	err = decodeStrictJson(bytes.NewReader(raw), item)
+
+	var strictErr *StrictJsonErr
+	if errors.As(err, &strictErr) {
+		strictErr.Offset += decoder.InputOffset() - int64(len(raw))
+	}
+
+	return err
+}
+
+// expectDelim reads the next token from decoder, returning an error if it is not delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("httpu: expected %v at offset %v, found %v", delim, decoder.InputOffset(), token)
+	}
+
+	return nil
+}
+
+// decodeEachErr writes the error encountered decoding a streamed request body, if there is
+// one. A body that is too large is answered with a HTTP 413, and any other error with a HTTP
+// 400 with the given detail, along with the index of the item if there is one. The error
+// itself is only logged, as the errors of encoding/json name the Go types being decoded.
+func (i *impl) decodeEachErr(err error, detail string, format string, args ...interface{}) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrBodyTooLarge) {
+		return i.WriteIfErr(err, http.StatusRequestEntityTooLarge, format, args...)
+	}
+
+	p := &Problem{Detail: detail}
+
+	var itemErr *ItemErr
+	if errors.As(err, &itemErr) {
+		p.Extensions = map[string]interface{}{"index": itemErr.Index}
+	}
+
+	return i.writeErr(err, http.StatusBadRequest, p, format, args...)
+}
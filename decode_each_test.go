@@ -0,0 +1,214 @@
+package httpu_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clavoie/erru"
+	"github.com/clavoie/httpu"
+)
+
+func TestDecodeEach(t *testing.T) {
+	type Row struct {
+		Id int
+	}
+
+	format := "import: %v"
+
+	t.Run("Success", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			contentType string
+			body        string
+		}{
+			{"Array", "application/json", ` [ {"Id":1}, {"Id":2} ,{"Id":3} ] `},
+			{"NoContentType", "", `[{"Id":1},{"Id":2},{"Id":3}]`},
+			{"Ndjson", "application/x-ndjson; charset=utf-8", "{\"Id\":1}\n{\"Id\":2}\n\n{\"Id\":3}\n"},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				r, w, _, i, finish := newTestImpl(t, test.body)
+				defer finish()
+
+				if test.contentType != "" {
+					r.Header.Set("Content-Type", test.contentType)
+				}
+
+				ids := make([]int, 0, 3)
+				failed := httpu.DecodeEach(i, func(index int, row *Row) error {
+					if index != len(ids) {
+						t.Fatal("Unexpected index", index)
+					}
+
+					ids = append(ids, row.Id)
+					return nil
+				}, format, 1)
+
+				if failed || len(ids) != 3 || ids[2] != 3 {
+					t.Fatal("Unexpected decode", failed, w.Code, ids)
+				}
+			})
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		_, _, _, i, finish := newTestImpl(t, `[]`)
+		defer finish()
+
+		if httpu.DecodeEach(i, func(index int, row *Row) error { return errors.New("no items expected") }, format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+	})
+
+	t.Run("InvalidItem", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, `[{"Id":1},{"Id":"two"},{"Id":3}]`, httpu.WithProblemDetails(true))
+		defer finish()
+
+		l.EXPECT().Warningf(NonEmptyStr(), 1, NonEmptyStr())
+		count := 0
+
+		failed := httpu.DecodeEach(i, func(index int, row *Row) error {
+			count++
+			return nil
+		}, format, 1)
+
+		if failed == false || count != 1 || w.Code != http.StatusBadRequest {
+			t.Fatal("Unexpected result", failed, count, w.Code)
+		}
+
+		var p struct {
+			Detail string
+			Index  int
+		}
+
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || p.Index != 1 || strings.Contains(p.Detail, "item 1") == false {
+			t.Fatal("Unexpected problem", err, w.Body.String())
+		}
+
+		if strings.Contains(w.Body.String(), "Row") || strings.Contains(w.Body.String(), "unmarshal") {
+			t.Fatal("Was not expecting the decode error in the response", w.Body.String())
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			contentType string
+			body        string
+		}{
+			{"NotArray", "application/json", `{"Id":1}`},
+			{"Unterminated", "application/json", `[{"Id":1}`},
+			{"TrailingData", "application/json", `[{"Id":1}] []`},
+			{"EmptyBody", "application/json", ``},
+			{"NdjsonSyntax", "application/x-ndjson", "{\"Id\":1}\n{\"Id\":\n"},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				r, w, l, i, finish := newTestImpl(t, test.body)
+				defer finish()
+
+				r.Header.Set("Content-Type", test.contentType)
+				l.EXPECT().Warningf(NonEmptyStr(), 1, NonEmptyStr())
+
+				if httpu.DecodeEach(i, func(index int, row *Row) error { return nil }, format, 1) == false {
+					t.Fatal("Was expecting an error")
+				}
+
+				if w.Code != http.StatusBadRequest || w.Body.Len() == 0 {
+					t.Fatal("Unexpected response", w.Code, w.Body.String())
+				}
+			})
+		}
+	})
+
+	t.Run("MaxItems", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, `[{"Id":1},{"Id":2},{"Id":3}]`, httpu.WithMaxItems(2))
+		defer finish()
+
+		l.EXPECT().Warningf(NonEmptyStr(), 1, httpu.ErrTooManyItems)
+		count := 0
+
+		failed := httpu.DecodeEach(i, func(index int, row *Row) error {
+			count++
+			return nil
+		}, format, 1)
+
+		if failed == false || count != 2 || w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatal("Unexpected result", failed, count, w.Code)
+		}
+	})
+
+	t.Run("CallbackErr", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, `[{"Id":1},{"Id":2},{"Id":3}]`)
+		defer finish()
+
+		l.EXPECT().Warningf(NonEmptyStr(), 1, NonEmptyStr()).Do(func(format string, args ...interface{}) {
+			var itemErr *httpu.ItemErr
+
+			if errors.As(args[1].(error), &itemErr) == false || itemErr.Index != 1 {
+				t.Fatal("Unexpected error", args[1])
+			}
+		})
+
+		count := 0
+		failed := httpu.DecodeEach(i, func(index int, row *Row) error {
+			count++
+
+			if index == 1 {
+				return erru.NewHttpError(http.StatusConflict, "duplicate")
+			}
+
+			return nil
+		}, format, 1)
+
+		if failed == false || count != 2 || w.Code != http.StatusConflict {
+			t.Fatal("Unexpected result", failed, count, w.Code)
+		}
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			body     string
+			expected string
+		}{
+			{"UnknownField", `[{"Id":1,"Extra":true}]`, `item 0: unknown field "Extra" at offset 9`},
+			{"DuplicateKey", `[{"Id":1}, {"Id":1,"Id":2}]`, `item 1: duplicate key "Id" at offset 19`},
+			{"DuplicateKeyCase", `[{"Id":1,"id":2}]`, `item 0: duplicate key "id" at offset 9`},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, w, l, i, finish := newTestImpl(t, test.body, httpu.WithStrictJson(true))
+				defer finish()
+
+				l.EXPECT().Warningf(NonEmptyStr(), 1, NonEmptyStr())
+
+				if httpu.DecodeEach(i, func(index int, row *Row) error { return nil }, format, 1) == false || w.Code != http.StatusBadRequest {
+					t.Fatal("Unexpected result", w.Code)
+				}
+
+				if strings.TrimSpace(w.Body.String()) != test.expected {
+					t.Fatal("Unexpected detail", w.Body.String())
+				}
+			})
+		}
+	})
+
+	t.Run("BodyTooLarge", func(t *testing.T) {
+		r, w, l, i, finish := newTestImpl(t, `[{"Id":1},{"Id":2},{"Id":3}]`, httpu.WithMaxBodyBytes(12))
+		defer finish()
+
+		r.ContentLength = -1
+		l.EXPECT().Warningf(NonEmptyStr(), 1, NonEmptyStr())
+
+		if httpu.DecodeEach(i, func(index int, row *Row) error { return nil }, format, 1) == false || w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatal("Unexpected result", w.Code)
+		}
+	})
+}
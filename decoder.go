@@ -0,0 +1,217 @@
+package httpu
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedMediaType is returned when no Decoder is registered for the Content-Type
+// of a request.
+var ErrUnsupportedMediaType = errors.New("httpu: unsupported media type")
+
+// Decoder decodes a request body into a destination object
+type Decoder interface {
+	// Decode reads the body from r and decodes it into dst
+	Decode(r io.Reader, dst interface{}) error
+}
+
+// DecoderFunc is an adapter that allows an ordinary func to be used as a Decoder
+type DecoderFunc func(r io.Reader, dst interface{}) error
+
+// Decode calls df(r, dst)
+func (df DecoderFunc) Decode(r io.Reader, dst interface{}) error {
+	return df(r, dst)
+}
+
+// decoders maps a media type to the Decoder used for request bodies of that type
+type decoders map[string]Decoder
+
+// defaultDecoders returns the decoders registered with every Impl
+func defaultDecoders() decoders {
+	return decoders{
+		"application/json":                  jsonDecoder{},
+		"application/x-www-form-urlencoded": DecoderFunc(decodeForm),
+		"application/xml":                   DecoderFunc(decodeXml),
+		"text/xml":                          DecoderFunc(decodeXml),
+	}
+}
+
+// find returns the Decoder registered for mediaType. If there is no exact match, media types
+// with a +json or +xml structured syntax suffix fall back to the json or xml decoder.
+func (d decoders) find(mediaType string) (Decoder, bool) {
+	decoder, hasDecoder := d[mediaType]
+
+	if hasDecoder {
+		return decoder, true
+	}
+
+	index := strings.LastIndex(mediaType, "+")
+	if index < 0 {
+		return nil, false
+	}
+
+	decoder, hasDecoder = d["application/"+mediaType[index+1:]]
+	return decoder, hasDecoder
+}
+
+// mediaTypes returns the sorted media types that have a Decoder registered
+func (d decoders) mediaTypes() []string {
+	mediaTypes := make([]string, 0, len(d))
+
+	for mediaType := range d {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+
+	sort.Strings(mediaTypes)
+	return mediaTypes
+}
+
+// jsonDecoder is the Decoder for application/json. It is its own type so that strict json
+// decoding can be applied when it is selected.
+type jsonDecoder struct{}
+
+func (jd jsonDecoder) Decode(r io.Reader, dst interface{}) error {
+	return json.NewDecoder(r).Decode(dst)
+}
+
+// decodeXml decodes a single xml document from r into dst
+func decodeXml(r io.Reader, dst interface{}) error {
+	return xml.NewDecoder(r).Decode(dst)
+}
+
+// decodeForm decodes an application/x-www-form-urlencoded body into dst. dst can be a
+// *url.Values, a *map[string][]string, or a pointer to a struct. Struct fields are matched
+// by their `form:"name"` tag, or by field name if they have no tag. Fields tagged with
+// `form:"-"` are skipped. Fields can be strings, bools, ints, uints, floats, or slices of
+// those types.
+func decodeForm(r io.Reader, dst interface{}) error {
+	data, err := io.ReadAll(r)
+
+	if err != nil {
+		return err
+	}
+
+	values, err := url.ParseQuery(string(data))
+
+	if err != nil {
+		return err
+	}
+
+	switch typedDst := dst.(type) {
+	case *url.Values:
+		*typedDst = values
+		return nil
+	case *map[string][]string:
+		*typedDst = values
+		return nil
+	}
+
+	dstValue := reflect.ValueOf(dst)
+	if dstValue.Kind() != reflect.Ptr || dstValue.IsNil() || dstValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("httpu: cannot decode form into %T", dst)
+	}
+
+	structValue := dstValue.Elem()
+	structType := structValue.Type()
+
+	for index := 0; index < structType.NumField(); index++ {
+		field := structType.Field(index)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		formValues, hasValue := values[name]
+		if hasValue == false || len(formValues) == 0 {
+			continue
+		}
+
+		err = setFormField(structValue.Field(index), formValues)
+
+		if err != nil {
+			return fmt.Errorf("httpu: form field %v: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// setFormField sets a struct field from the values of a form field
+func setFormField(field reflect.Value, values []string) error {
+	if field.Kind() != reflect.Slice {
+		return setFormValue(field, values[0])
+	}
+
+	slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+	for index, value := range values {
+		err := setFormValue(slice.Index(index), value)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	field.Set(slice)
+	return nil
+}
+
+// setFormValue parses value into field based on the kind of the field
+func setFormValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %v", field.Type())
+	}
+
+	return nil
+}
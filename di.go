@@ -1,11 +1,21 @@
 package httpu
 
-import "github.com/clavoie/di/v2"
+import (
+	"net/http"
+
+	"github.com/clavoie/di/v2"
+	"github.com/clavoie/logu/v2"
+)
 
 // NewDiDefs returns a new collection of di definitions that
-// can be used to inject httpu into your project.
-func NewDiDefs() []*di.Def {
+// can be used to inject httpu into your project. Each Impl
+// that is resolved is configured with the given options.
+func NewDiDefs(opts ...Option) []*di.Def {
+	newImpl := func(w http.ResponseWriter, r *http.Request, l logu.Logger) Impl {
+		return NewImplWithOptions(w, r, l, opts...)
+	}
+
 	return []*di.Def{
-		{NewImpl, di.PerHttpRequest},
+		{Constructor: newImpl, Lifetime: di.PerHttpRequest},
 	}
 }
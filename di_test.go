@@ -1,9 +1,16 @@
 package httpu_test
 
 import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/clavoie/di/v2"
 	"github.com/clavoie/httpu"
+	"github.com/clavoie/logu/v2"
 )
 
 func TestNewDiDefs(t *testing.T) {
@@ -18,3 +25,38 @@ func TestNewDiDefs(t *testing.T) {
 		t.Fatal("Not expecting defs to match")
 	}
 }
+
+func TestNewDiDefsOptions(t *testing.T) {
+	var decoded string
+	decoder := httpu.DecoderFunc(func(r io.Reader, dst interface{}) error {
+		data, err := ioutil.ReadAll(r)
+		*dst.(*string) = string(data)
+		return err
+	})
+
+	loggerDefs := []*di.Def{{Constructor: logu.NewNullLogger, Lifetime: di.Singleton}}
+	onErr := func(err *di.ErrResolve, w http.ResponseWriter, r *http.Request) {
+		t.Fatal(err.String())
+	}
+	resolver, err := di.NewResolver(onErr, loggerDefs, httpu.NewDiDefs(httpu.WithDecoder("text/plain", decoder)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler, err := resolver.HttpHandler(func(i httpu.Impl) {
+		i.DecodeOr400(&decoded, "Could not decode")
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "http://test.com", strings.NewReader("hello"))
+	r.Header.Set("Content-Type", "text/plain")
+	handler(httptest.NewRecorder(), r)
+
+	if decoded != "hello" {
+		t.Fatal("Was expecting the registered decoder to be used", decoded)
+	}
+}
@@ -0,0 +1,132 @@
+package httpu
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Disposition is the disposition type of a Content-Disposition header, which tells the
+// browser whether to display a response or save it as a file
+type Disposition string
+
+const (
+	// DispositionAttachment asks the browser to save the response as a file
+	DispositionAttachment Disposition = "attachment"
+
+	// DispositionInline asks the browser to display the response, using the file name if the
+	// user saves it
+	DispositionInline Disposition = "inline"
+)
+
+// ContentDisposition returns the value of a Content-Disposition header, as described by
+// RFC 6266, for the disposition type and file name. Path separators, control characters, and
+// bidirectional text controls are removed from the file name, runs of dots are collapsed into
+// one, and leading and trailing dots are removed, so that the name cannot climb out of a
+// directory or disguise its extension. The name is written as a quoted filename parameter, with
+// any characters that are not printable ASCII replaced by underscores. If the name is not
+// entirely ASCII a filename* parameter holding the UTF-8 name, encoded as described by
+// RFC 5987, follows it:
+//
+//	attachment; filename="Gr__e.txt"; filename*=UTF-8''Gr%C3%BC%C3%9Fe.txt
+//
+// If nothing is left of the file name only the disposition type is returned.
+func ContentDisposition(disposition Disposition, filename string) string {
+	filename = cleanFilename(filename)
+
+	if filename == "" {
+		return string(disposition)
+	}
+
+	value := string(disposition) + "; filename=" + quoteAsciiFilename(filename)
+
+	if isAscii(filename) == false {
+		value += "; filename*=UTF-8''" + encodeExtValue(filename)
+	}
+
+	return value
+}
+
+// cleanFilename removes path separators, control characters, bidirectional text controls,
+// and invalid UTF-8 from the file name. Runs of dots are collapsed into a single dot, and any
+// leading or trailing dots and spaces are removed.
+func cleanFilename(filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == utf8.RuneError || unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r) {
+			return -1
+		}
+
+		return r
+	}, filename)
+
+	for strings.Contains(filename, "..") {
+		filename = strings.ReplaceAll(filename, "..", ".")
+	}
+
+	return strings.TrimFunc(filename, func(r rune) bool { return r == '.' || unicode.IsSpace(r) })
+}
+
+// isAscii returns true if every character of s is ASCII
+func isAscii(s string) bool {
+	for index := 0; index < len(s); index++ {
+		if s[index] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// quoteAsciiFilename returns the file name as a quoted string, replacing any character that
+// is not printable ASCII with an underscore and escaping quotes and backslashes
+func quoteAsciiFilename(filename string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	for _, r := range filename {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
+
+// encodeExtValue percent encodes every byte of s that is not an attr-char of RFC 5987
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+
+	for index := 0; index < len(s); index++ {
+		c := s[index]
+
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+
+	return b.String()
+}
+
+// isAttrChar returns true if c is an attr-char of RFC 5987, which can appear in an ext-value
+// without being percent encoded
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,45 @@
+package httpu_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clavoie/httpu"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		disposition httpu.Disposition
+		filename    string
+		expected    string
+	}{
+		{httpu.DispositionAttachment, "report.csv", `attachment; filename="report.csv"`},
+		{httpu.DispositionInline, "report.pdf", `inline; filename="report.pdf"`},
+		{httpu.DispositionAttachment, `say "hi" \ bye.txt`, `attachment; filename="say \"hi\"  bye.txt"`},
+		{httpu.DispositionAttachment, "a\r\nSet-Cookie: x=1.txt", `attachment; filename="aSet-Cookie: x=1.txt"`},
+		{httpu.DispositionAttachment, "../../etc/passwd", `attachment; filename="etcpasswd"`},
+		{httpu.DispositionAttachment, "..", "attachment"},
+		{httpu.DispositionAttachment, "report..final...pdf", `attachment; filename="report.final.pdf"`},
+		{httpu.DispositionAttachment, "invoice\u202Efdp.exe", `attachment; filename="invoicefdp.exe"`},
+		{httpu.DispositionAttachment, "\u2067a\u2069\u200Fb\u061C.txt", `attachment; filename="ab.txt"`},
+		{httpu.DispositionAttachment, "Grüße.txt", `attachment; filename="Gr__e.txt"; filename*=UTF-8''Gr%C3%BC%C3%9Fe.txt`},
+		{httpu.DispositionInline, "報告 (1).pdf", `inline; filename="__ (1).pdf"; filename*=UTF-8''%E5%A0%B1%E5%91%8A%20%281%29.pdf`},
+		{httpu.DispositionAttachment, " /\x00 ", "attachment"},
+	}
+
+	for _, test := range tests {
+		if actual := httpu.ContentDisposition(test.disposition, test.filename); actual != test.expected {
+			t.Fatal("Unexpected content disposition", test.filename, actual)
+		}
+	}
+}
+
+func TestSetDispositionWithName(t *testing.T) {
+	w := httptest.NewRecorder()
+	httpu.SetDispositionWithName(w, httpu.DispositionInline, "%v.txt", "Grüße")
+
+	expected := `inline; filename="Gr__e.txt"; filename*=UTF-8''Gr%C3%BC%C3%9Fe.txt`
+	if actual := w.Header().Get("Content-Disposition"); actual != expected {
+		t.Fatal("Unexpected content disposition", actual)
+	}
+}
@@ -0,0 +1,78 @@
+package httpu
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// downloadReader records the first error, other than io.EOF, returned while reading the
+// content of a download
+type downloadReader struct {
+	io.ReadSeeker
+	err error
+}
+
+func (dr *downloadReader) Read(p []byte) (int, error) {
+	n, err := dr.ReadSeeker.Read(p)
+
+	if err != nil && err != io.EOF && dr.err == nil {
+		dr.err = err
+	}
+
+	return n, err
+}
+
+// downloadWriter records the first error returned while writing a download to the response
+type downloadWriter struct {
+	http.ResponseWriter
+	err error
+}
+
+func (dw *downloadWriter) Write(p []byte) (int, error) {
+	n, err := dw.ResponseWriter.Write(p)
+
+	if err != nil && dw.err == nil {
+		dw.err = err
+	}
+
+	return n, err
+}
+
+// downloadETag returns an entity tag built from the size and modification time of content.
+// The tag is strong, as http.ServeContent only honours If-Range and If-Match with strong
+// tags, and those are what let clients resume an interrupted download.
+func downloadETag(size int64, modtime time.Time) string {
+	return fmt.Sprintf(`"%x-%x"`, size, modtime.UnixNano())
+}
+
+func (i *impl) ServeDownload(name string, modtime time.Time, content io.ReadSeeker) {
+	size, err := content.Seek(0, io.SeekEnd)
+
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+
+	if i.Write500IfErr(err, "Could not find the size of download %v", name) {
+		return
+	}
+
+	filename := cleanFilename(name)
+	header := i.w.Header()
+	header.Set("Content-Disposition", ContentDisposition(DispositionAttachment, filename))
+
+	if header.Get("ETag") == "" && modtime.IsZero() == false && modtime.Unix() != 0 {
+		header.Set("ETag", downloadETag(size, modtime))
+	}
+
+	reader := &downloadReader{ReadSeeker: content}
+	writer := &downloadWriter{ResponseWriter: i.w}
+	http.ServeContent(writer, i.r, filename, modtime, reader)
+
+	if reader.err != nil {
+		i.l.Errorf("Could not read download %v: %v", name, reader.err)
+	} else if writer.err != nil {
+		i.l.Warningf("Could not write download %v: %v", name, writer.err)
+	}
+}
@@ -0,0 +1,195 @@
+package httpu_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clavoie/httpu"
+)
+
+// failingReadSeeker fails every read after seeking
+type failingReadSeeker struct {
+	io.ReadSeeker
+}
+
+func (frs *failingReadSeeker) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestServeDownload(t *testing.T) {
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	modtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	serve := func(t *testing.T, method string, headers map[string]string) *http.Response {
+		r, w, _, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		r.Method = method
+		for key, value := range headers {
+			r.Header.Set(key, value)
+		}
+
+		i.ServeDownload("data/report.txt", modtime, strings.NewReader(content))
+		return w.Result()
+	}
+
+	t.Run("Full", func(t *testing.T) {
+		resp := serve(t, "GET", nil)
+		body, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusOK || string(body) != content {
+			t.Fatal("Unexpected response", resp.StatusCode, string(body))
+		}
+
+		expected := map[string]string{
+			"Content-Disposition": `attachment; filename="datareport.txt"`,
+			"Content-Length":      "36",
+			"Content-Type":        "text/plain; charset=utf-8",
+			"Last-Modified":       modtime.Format(http.TimeFormat),
+			"Accept-Ranges":       "bytes",
+		}
+
+		for key, value := range expected {
+			if actual := resp.Header.Get(key); actual != value {
+				t.Fatal("Unexpected header", key, actual)
+			}
+		}
+
+		if etag := resp.Header.Get("ETag"); strings.HasPrefix(etag, `"`) == false {
+			t.Fatal("Unexpected etag", etag)
+		}
+	})
+
+	t.Run("Head", func(t *testing.T) {
+		resp := serve(t, "HEAD", nil)
+		body, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusOK || len(body) != 0 || resp.Header.Get("Content-Length") != "36" {
+			t.Fatal("Unexpected response", resp.StatusCode, len(body), resp.Header.Get("Content-Length"))
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		resp := serve(t, "GET", map[string]string{"Range": "bytes=10-15"})
+		body, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusPartialContent || string(body) != "abcdef" {
+			t.Fatal("Unexpected response", resp.StatusCode, string(body))
+		}
+
+		if actual := resp.Header.Get("Content-Range"); actual != "bytes 10-15/36" {
+			t.Fatal("Unexpected content range", actual)
+		}
+	})
+
+	t.Run("MultiRange", func(t *testing.T) {
+		resp := serve(t, "GET", map[string]string{"Range": "bytes=0-1,10-11"})
+		body, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusPartialContent || strings.HasPrefix(resp.Header.Get("Content-Type"), "multipart/byteranges") == false {
+			t.Fatal("Unexpected response", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+
+		if strings.Contains(string(body), "01") == false || strings.Contains(string(body), "ab") == false {
+			t.Fatal("Unexpected body", string(body))
+		}
+	})
+
+	t.Run("RangeNotSatisfiable", func(t *testing.T) {
+		resp := serve(t, "GET", map[string]string{"Range": "bytes=100-200"})
+
+		if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+			t.Fatal("Unexpected code", resp.StatusCode)
+		}
+	})
+
+	t.Run("IfNoneMatch", func(t *testing.T) {
+		etag := serve(t, "GET", nil).Header.Get("ETag")
+		resp := serve(t, "GET", map[string]string{"If-None-Match": etag})
+
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatal("Unexpected code", resp.StatusCode)
+		}
+	})
+
+	t.Run("IfRange", func(t *testing.T) {
+		etag := serve(t, "GET", nil).Header.Get("ETag")
+
+		tests := []struct {
+			name       string
+			ifRange    string
+			statusCode int
+			body       string
+		}{
+			{"Match", etag, http.StatusPartialContent, "abcdef"},
+			{"Changed", `"other"`, http.StatusOK, content},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				resp := serve(t, "GET", map[string]string{"Range": "bytes=10-15", "If-Range": test.ifRange})
+				body, _ := io.ReadAll(resp.Body)
+
+				if resp.StatusCode != test.statusCode || string(body) != test.body {
+					t.Fatal("Unexpected response", resp.StatusCode, string(body))
+				}
+			})
+		}
+	})
+
+	t.Run("IfMatch", func(t *testing.T) {
+		etag := serve(t, "GET", nil).Header.Get("ETag")
+
+		tests := []struct {
+			name       string
+			ifMatch    string
+			statusCode int
+		}{
+			{"Match", etag, http.StatusPartialContent},
+			{"Changed", `"other"`, http.StatusPreconditionFailed},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				resp := serve(t, "GET", map[string]string{"Range": "bytes=10-15", "If-Match": test.ifMatch})
+
+				if resp.StatusCode != test.statusCode {
+					t.Fatal("Unexpected code", resp.StatusCode)
+				}
+			})
+		}
+	})
+
+	t.Run("IfModifiedSince", func(t *testing.T) {
+		resp := serve(t, "GET", map[string]string{"If-Modified-Since": modtime.Add(time.Hour).Format(http.TimeFormat)})
+
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatal("Unexpected code", resp.StatusCode)
+		}
+	})
+
+	t.Run("Package", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://test.com/report", nil)
+		w := httptest.NewRecorder()
+		httpu.ServeDownload(w, r, "report.json", time.Time{}, strings.NewReader(`{}`))
+
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" || w.Header().Get("ETag") != "" {
+			t.Fatal("Unexpected response", w.Code, w.Header())
+		}
+	})
+
+	t.Run("ReadFailure", func(t *testing.T) {
+		r, _, l, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		r.Method = "GET"
+		l.EXPECT().Errorf(NonEmptyStr(), "report.bin", NonEmptyStr())
+
+		i.ServeDownload("report.bin", modtime, &failingReadSeeker{strings.NewReader(content)})
+	})
+}
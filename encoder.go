@@ -0,0 +1,154 @@
+package httpu
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+var (
+	// ErrNotAcceptable is returned when none of the registered Encoders produce a media type
+	// accepted by the request.
+	ErrNotAcceptable = errors.New("httpu: not acceptable")
+
+	// ErrNoLocation is logged when EncodeCreatedOr500 is called without the location of the
+	// created resource.
+	ErrNoLocation = errors.New("httpu: created resource has no location")
+)
+
+// Encoder encodes a source object into a response body
+type Encoder interface {
+	// Encode encodes src and writes the result to w
+	Encode(w io.Writer, src interface{}) error
+}
+
+// EncoderFunc is an adapter that allows an ordinary func to be used as an Encoder
+type EncoderFunc func(w io.Writer, src interface{}) error
+
+// Encode calls ef(w, src)
+func (ef EncoderFunc) Encode(w io.Writer, src interface{}) error {
+	return ef(w, src)
+}
+
+// encoderEntry is an Encoder and the media type of the content it produces
+type encoderEntry struct {
+	mediaType string
+	encoder   Encoder
+}
+
+// encoders is the ordered collection of registered Encoders. When the Accept header of a
+// request prefers several media types equally, the earliest registered Encoder wins.
+type encoders []encoderEntry
+
+// defaultEncoders returns the encoders registered with every Impl
+func defaultEncoders() encoders {
+	return encoders{
+		{"application/json", EncoderFunc(encodeJson)},
+		{"application/xml", EncoderFunc(encodeXml)},
+		{"text/csv", EncoderFunc(encodeCsv)},
+	}
+}
+
+// mediaTypes returns the media types of the encoders in order
+func (e encoders) mediaTypes() []string {
+	mediaTypes := make([]string, len(e))
+
+	for index, entry := range e {
+		mediaTypes[index] = entry.mediaType
+	}
+
+	return mediaTypes
+}
+
+// encodeJson encodes src to w as json
+func encodeJson(w io.Writer, src interface{}) error {
+	return json.NewEncoder(w).Encode(src)
+}
+
+// encodeXml encodes src to w as an xml document
+func encodeXml(w io.Writer, src interface{}) error {
+	_, err := io.WriteString(w, xml.Header)
+
+	if err != nil {
+		return err
+	}
+
+	return xml.NewEncoder(w).Encode(src)
+}
+
+// encodeCsv encodes src to w as csv. src can be a [][]string, or a slice of structs or
+// struct pointers. For a slice of structs the first row is a header made up of each
+// field's `csv:"name"` tag, or its name if it has no tag. Fields tagged with `csv:"-"`
+// are skipped, and values are formatted with fmt.Sprint.
+func encodeCsv(w io.Writer, src interface{}) error {
+	writer := csv.NewWriter(w)
+
+	if records, isRecords := src.([][]string); isRecords {
+		return writer.WriteAll(records)
+	}
+
+	srcValue := reflect.Indirect(reflect.ValueOf(src))
+	if srcValue.Kind() != reflect.Slice && srcValue.Kind() != reflect.Array {
+		return fmt.Errorf("httpu: cannot encode %T as csv", src)
+	}
+
+	elemType := srcValue.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("httpu: cannot encode %T as csv", src)
+	}
+
+	fields := make([]int, 0, elemType.NumField())
+	header := make([]string, 0, elemType.NumField())
+
+	for index := 0; index < elemType.NumField(); index++ {
+		field := elemType.Field(index)
+		name := field.Tag.Get("csv")
+
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, index)
+		header = append(header, name)
+	}
+
+	err := writer.Write(header)
+
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(fields))
+	for row := 0; row < srcValue.Len(); row++ {
+		elem := reflect.Indirect(srcValue.Index(row))
+
+		for column, index := range fields {
+			if elem.IsValid() {
+				record[column] = fmt.Sprint(elem.Field(index).Interface())
+			} else {
+				record[column] = ""
+			}
+		}
+
+		err = writer.Write(record)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
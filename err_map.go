@@ -0,0 +1,96 @@
+package httpu
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// errorType is the reflect.Type of error
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// ErrMap maps errors to the HTTP status code and public message written for them by
+// Impl.WriteErr. An ErrMap is typically built once at startup and registered with
+// WithErrMap. Its methods may not be called concurrently with Add or AddType.
+//
+//	errMap := httpu.NewErrMap().
+//		Add(sql.ErrNoRows, http.StatusNotFound, "Not found").
+//		Add(context.DeadlineExceeded, http.StatusGatewayTimeout, "").
+//		AddType((*ConflictErr)(nil), http.StatusConflict, "Already exists")
+type ErrMap struct {
+	entries []errMapEntry
+}
+
+// errMapEntry maps either an error value or an error type to a status code and message
+type errMapEntry struct {
+	message    string
+	statusCode int
+	target     error
+	targetType reflect.Type
+}
+
+// NewErrMap returns a new, empty ErrMap
+func NewErrMap() *ErrMap {
+	return new(ErrMap)
+}
+
+// Add maps any error that matches target, as reported by errors.Is, to the status code and
+// message. The message is written to the response, so it must be safe to show to clients.
+// An empty message writes no detail. The ErrMap is returned to allow calls to be chained.
+func (em *ErrMap) Add(target error, statusCode int, message string) *ErrMap {
+	em.entries = append(em.entries, errMapEntry{
+		message:    message,
+		statusCode: statusCode,
+		target:     target,
+	})
+
+	return em
+}
+
+// AddType maps any error in the chain of an error that has the same type as target, as
+// reported by errors.As, to the status code and message. target is a value of the type to
+// match, usually a nil pointer such as (*MyErr)(nil). The message is written to the
+// response, so it must be safe to show to clients. An empty message writes no detail. The
+// ErrMap is returned to allow calls to be chained.
+//
+// AddType panics if the type of target does not implement error.
+func (em *ErrMap) AddType(target interface{}, statusCode int, message string) *ErrMap {
+	targetType := reflect.TypeOf(target)
+
+	if targetType == nil || targetType.Implements(errorType) == false {
+		panic(fmt.Sprintf("httpu: ErrMap.AddType target type %T does not implement error", target))
+	}
+
+	em.entries = append(em.entries, errMapEntry{
+		message:    message,
+		statusCode: statusCode,
+		targetType: targetType,
+	})
+
+	return em
+}
+
+// Lookup returns the status code and message of the first mapping, in the order they were
+// added, that matches err. false is returned if no mapping matches err.
+func (em *ErrMap) Lookup(err error) (int, string, bool) {
+	if em == nil || err == nil {
+		return 0, "", false
+	}
+
+	for _, entry := range em.entries {
+		if entry.matches(err) {
+			return entry.statusCode, entry.message, true
+		}
+	}
+
+	return 0, "", false
+}
+
+// matches returns true if err matches the target error or target type of the entry
+func (eme *errMapEntry) matches(err error) bool {
+	if eme.targetType == nil {
+		return errors.Is(err, eme.target)
+	}
+
+	return errors.As(err, reflect.New(eme.targetType).Interface())
+}
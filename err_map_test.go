@@ -0,0 +1,116 @@
+package httpu_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clavoie/erru"
+	"github.com/clavoie/httpu"
+)
+
+type conflictErr struct{ id int }
+
+func (ce *conflictErr) Error() string { return fmt.Sprintf("conflict on %v", ce.id) }
+
+type valueErr struct{}
+
+func (ve valueErr) Error() string { return "value err" }
+
+var errNotFound = errors.New("not found")
+
+func TestErrMap(t *testing.T) {
+	errMap := httpu.NewErrMap().
+		Add(sql.ErrNoRows, http.StatusNotFound, "Not found").
+		Add(errNotFound, http.StatusNotFound, "Widget not found").
+		Add(context.DeadlineExceeded, http.StatusGatewayTimeout, "").
+		AddType((*conflictErr)(nil), http.StatusConflict, "Already exists").
+		AddType(valueErr{}, http.StatusTeapot, "Teapot")
+
+	t.Run("Lookup", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			err        error
+			statusCode int
+			message    string
+			found      bool
+		}{
+			{"Nil", nil, 0, "", false},
+			{"Unmapped", errors.New("unmapped"), 0, "", false},
+			{"Sentinel", sql.ErrNoRows, http.StatusNotFound, "Not found", true},
+			{"WrappedSentinel", fmt.Errorf("loading widget: %w", errNotFound), http.StatusNotFound, "Widget not found", true},
+			{"NoMessage", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "", true},
+			{"Type", fmt.Errorf("saving: %w", &conflictErr{1}), http.StatusConflict, "Already exists", true},
+			{"ValueType", fmt.Errorf("brewing: %w", valueErr{}), http.StatusTeapot, "Teapot", true},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				statusCode, message, found := errMap.Lookup(test.err)
+
+				if statusCode != test.statusCode || message != test.message || found != test.found {
+					t.Fatal("Unexpected lookup", statusCode, message, found)
+				}
+			})
+		}
+	})
+	t.Run("LookupNilMap", func(t *testing.T) {
+		var nilMap *httpu.ErrMap
+
+		if _, _, found := nilMap.Lookup(sql.ErrNoRows); found {
+			t.Fatal("Was not expecting a mapping")
+		}
+	})
+	t.Run("LookupOrder", func(t *testing.T) {
+		m := httpu.NewErrMap().
+			Add(errNotFound, http.StatusGone, "first").
+			Add(errNotFound, http.StatusNotFound, "second")
+
+		if statusCode, message, _ := m.Lookup(errNotFound); statusCode != http.StatusGone || message != "first" {
+			t.Fatal("Was expecting the first mapping", statusCode, message)
+		}
+	})
+	t.Run("AddTypeNotErr", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Was expecting a panic")
+			}
+		}()
+
+		httpu.NewErrMap().AddType(1, http.StatusTeapot, "")
+	})
+	t.Run("WriteErr", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, ``, httpu.WithErrMap(errMap))
+		defer finish()
+
+		err := fmt.Errorf("loading widget 7: %w", errNotFound)
+		l.EXPECT().Warningf("write: %v: %v", 1, err)
+
+		if i.WriteErr(err, "write: %v", 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusNotFound || strings.TrimSpace(w.Body.String()) != "Widget not found" {
+			t.Fatal("Unexpected response", w.Code, w.Body.String())
+		}
+	})
+	t.Run("WriteErrFallback", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, ``, httpu.WithErrMap(errMap))
+		defer finish()
+
+		err := erru.NewHttpUnauthorized("no session")
+		l.EXPECT().Warningf("write: %v: %v", 1, err)
+
+		if i.WriteErr(err, "write: %v", 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusUnauthorized || w.Body.Len() != 0 {
+			t.Fatal("Unexpected response", w.Code, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package httpu
+
+import (
+	"errors"
+	"net/http"
+)
+
+// statusCoder is implemented by errors that carry the HTTP status code of the response they
+// should produce, such as erru.HttpErr
+type statusCoder interface {
+	StatusCode() int
+}
+
+// resolveErr returns the status code and public details written for err by WriteErr. The
+// ErrMap is consulted first, followed by the status code of the error itself.
+func resolveErr(errMap *ErrMap, err error) (int, *Problem) {
+	statusCode, message, found := errMap.Lookup(err)
+
+	if found == false {
+		return errStatusCode(err), nil
+	}
+
+	if message == "" {
+		return statusCode, nil
+	}
+
+	return statusCode, &Problem{Detail: message}
+}
+
+// errStatusCode returns the status code of the first error in the chain of err that
+// implements StatusCode() int. Status codes outside of the 4xx and 5xx ranges are ignored.
+// http.StatusInternalServerError is returned if no status code is found.
+func errStatusCode(err error) int {
+	var coder statusCoder
+
+	if errors.As(err, &coder) {
+		statusCode := coder.StatusCode()
+
+		if statusCode >= 400 && statusCode <= 599 {
+			return statusCode
+		}
+	}
+
+	return http.StatusInternalServerError
+}
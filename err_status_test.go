@@ -0,0 +1,80 @@
+package httpu_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clavoie/erru"
+	"github.com/clavoie/httpu"
+)
+
+type statusErr int
+
+func (se statusErr) Error() string   { return fmt.Sprintf("status %v", int(se)) }
+func (se statusErr) StatusCode() int { return int(se) }
+
+func TestWriteErr(t *testing.T) {
+	format := "write: %v"
+	errFormat := format + ": %v"
+
+	t.Run("Nil", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, ``)
+		defer finish()
+
+		if i.WriteErr(nil, format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Code != http.StatusOK {
+			t.Fatal("Unexpected code", w.Code)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+	}{
+		{"HttpErr", erru.NewHttpNotFound("not found"), http.StatusNotFound},
+		{"WrappedHttpErr", fmt.Errorf("loading: %w", erru.NewHttpForbidden("forbidden")), http.StatusForbidden},
+		{"StatusCoder", fmt.Errorf("a: %w", fmt.Errorf("b: %w", statusErr(http.StatusServiceUnavailable))), http.StatusServiceUnavailable},
+		{"InvalidStatus", statusErr(http.StatusOK), http.StatusInternalServerError},
+		{"Plain", errors.New("plain"), http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, w, l, i, finish := newTestImpl(t, ``)
+			defer finish()
+
+			if test.statusCode >= 500 {
+				l.EXPECT().Errorf(errFormat, 1, test.err)
+			} else {
+				l.EXPECT().Warningf(errFormat, 1, test.err)
+			}
+
+			if i.WriteErr(test.err, format, 1) == false {
+				t.Fatal("Was expecting an error")
+			}
+
+			if w.Code != test.statusCode {
+				t.Fatalf("expecting code: %v, found %v", test.statusCode, w.Code)
+			}
+		})
+	}
+
+	t.Run("Package", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		if httpu.WriteErr(erru.NewHttpBadRequest("bad"), w, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatal("Unexpected code", w.Code)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package httpu
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// ETagMode selects how the encode functions generate an ETag from the encoded response
+type ETagMode int
+
+const (
+	// ETagOff generates no ETag, which is the default
+	ETagOff ETagMode = iota
+
+	// ETagStrong generates a strong ETag, for responses that are byte for byte identical
+	// whenever their ETags match
+	ETagStrong
+
+	// ETagWeak generates a weak ETag, for responses that are equivalent whenever their ETags
+	// match, even if their bytes differ, such as when they are compressed
+	ETagWeak
+)
+
+// hashETag returns an ETag of the mode for the encoded response body
+func hashETag(mode ETagMode, body []byte) string {
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+	if mode == ETagWeak {
+		return "W/" + etag
+	}
+
+	return etag
+}
+
+// quoteETag returns etag as a quoted entity tag, quoting it if it is not already quoted
+func quoteETag(etag string) string {
+	if strings.HasSuffix(etag, `"`) && (strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`)) {
+		return etag
+	}
+
+	return `"` + etag + `"`
+}
+
+// etagMatches returns true if the If-None-Match header matches etag using the weak
+// comparison of RFC 7232, under which W/"a" matches "a"
+func etagMatches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified returns true if the request is a GET or HEAD whose conditional headers match
+// the ETag and Last-Modified headers of the response. If-Modified-Since is only checked if
+// the request has no If-None-Match header, as described by RFC 7232.
+func (i *impl) notModified() bool {
+	if i.r == nil || (i.r.Method != http.MethodGet && i.r.Method != http.MethodHead) {
+		return false
+	}
+
+	header := i.w.Header()
+
+	if ifNoneMatch := i.r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := header.Get("ETag")
+		return etag != "" && etagMatches(ifNoneMatch, etag)
+	}
+
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(i.r.Header.Get("If-Modified-Since"))
+	return err == nil && lastModified.After(ifModifiedSince) == false
+}
+
+// writeNotModified writes a HTTP 304 to the response without a body
+func (i *impl) writeNotModified() {
+	header := i.w.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	i.w.WriteHeader(http.StatusNotModified)
+}
+
+// setValidators sets the ETag and Last-Modified headers of the response to those given with
+// WithETagValue and WithLastModified
+func (i *impl) setValidators() {
+	header := i.w.Header()
+
+	if i.o.etag != "" {
+		header.Set("ETag", i.o.etag)
+	}
+
+	if i.o.lastModified.IsZero() == false {
+		header.Set("Last-Modified", i.o.lastModified.UTC().Format(http.TimeFormat))
+	}
+}
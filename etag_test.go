@@ -0,0 +1,170 @@
+package httpu_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clavoie/httpu"
+)
+
+func TestETag(t *testing.T) {
+	type Json struct {
+		Field string
+	}
+
+	src := &Json{"value"}
+
+	encode := func(t *testing.T, method string, headers map[string]string, opts ...httpu.Option) (int, http.Header, string) {
+		r, w, _, i, finish := newTestImpl(t, "", opts...)
+		defer finish()
+
+		r.Method = method
+		for key, value := range headers {
+			r.Header.Set(key, value)
+		}
+
+		if i.EncodeJsonOr500(src, "encode") {
+			t.Fatal("Was not expecting an error")
+		}
+
+		return w.Code, w.Header(), w.Body.String()
+	}
+
+	t.Run("Generated", func(t *testing.T) {
+		for _, mode := range []httpu.ETagMode{httpu.ETagStrong, httpu.ETagWeak} {
+			_, header, body := encode(t, "GET", nil, httpu.WithETag(mode))
+			etag := header.Get("ETag")
+
+			if etag == "" || strings.HasPrefix(etag, "W/") != (mode == httpu.ETagWeak) || body == "" {
+				t.Fatal("Unexpected etag", mode, etag)
+			}
+
+			_, header, _ = encode(t, "GET", nil, httpu.WithETag(mode))
+			if header.Get("ETag") != etag {
+				t.Fatal("Was expecting the etag to be stable", etag, header.Get("ETag"))
+			}
+
+			code, header, body := encode(t, "GET", map[string]string{"If-None-Match": `"other", ` + strings.TrimPrefix(etag, "W/")}, httpu.WithETag(mode))
+			if code != http.StatusNotModified || body != "" || header.Get("ETag") != etag || header.Get("Content-Type") != "" || header.Get("Content-Length") != "" {
+				t.Fatal("Unexpected not modified response", code, header, body)
+			}
+
+			code, _, _ = encode(t, "POST", map[string]string{"If-None-Match": etag}, httpu.WithETag(mode))
+			if code != http.StatusOK {
+				t.Fatal("Was not expecting a not modified response to a POST", code)
+			}
+		}
+	})
+
+	t.Run("Off", func(t *testing.T) {
+		_, header, _ := encode(t, "GET", nil)
+
+		if header.Get("ETag") != "" {
+			t.Fatal("Was not expecting an etag", header.Get("ETag"))
+		}
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		_, header, _ := encode(t, "GET", nil, httpu.WithETag(httpu.ETagStrong), httpu.WithResponseBufferSize(4))
+
+		if header.Get("ETag") != "" {
+			t.Fatal("Was not expecting an etag for a streamed response", header.Get("ETag"))
+		}
+	})
+
+	t.Run("Value", func(t *testing.T) {
+		tests := []struct {
+			value       string
+			ifNoneMatch string
+			expected    int
+		}{
+			{"v1", `"v1"`, http.StatusNotModified},
+			{`W/"v1"`, `"v1"`, http.StatusNotModified},
+			{"v1", "*", http.StatusNotModified},
+			{"v1", `"v2"`, http.StatusOK},
+		}
+
+		for _, test := range tests {
+			code, header, _ := encode(t, "GET", map[string]string{"If-None-Match": test.ifNoneMatch}, httpu.WithETag(httpu.ETagStrong), httpu.WithETagValue(test.value))
+
+			if code != test.expected {
+				t.Fatal("Unexpected code", test.value, test.ifNoneMatch, code)
+			}
+
+			if etag := header.Get("ETag"); etag != `"v1"` && etag != `W/"v1"` {
+				t.Fatal("Unexpected etag", etag)
+			}
+		}
+	})
+
+	t.Run("Compressed", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []httpu.Option
+		}{
+			{"Generated", []httpu.Option{httpu.WithETag(httpu.ETagStrong)}},
+			{"Value", []httpu.Option{httpu.WithETagValue("v1")}},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				opts := append(test.opts, httpu.WithCompression(true), httpu.WithCompressionMinSize(0))
+				_, header, _ := encode(t, "GET", map[string]string{"Accept-Encoding": "gzip"}, opts...)
+				etag := header.Get("ETag")
+
+				if header.Get("Content-Encoding") != "gzip" || strings.HasPrefix(etag, "W/") == false {
+					t.Fatal("Unexpected compressed response", header)
+				}
+
+				code, header, _ := encode(t, "GET", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag}, opts...)
+				if code != http.StatusNotModified || header.Get("ETag") != etag {
+					t.Fatal("Unexpected not modified response", code, header.Get("ETag"), etag)
+				}
+			})
+		}
+	})
+	t.Run("LastModified", func(t *testing.T) {
+		modtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		opts := []httpu.Option{httpu.WithLastModified(modtime.Add(500 * time.Millisecond))}
+
+		tests := []struct {
+			headers  map[string]string
+			expected int
+		}{
+			{map[string]string{"If-Modified-Since": modtime.Format(http.TimeFormat)}, http.StatusNotModified},
+			{map[string]string{"If-Modified-Since": modtime.Add(-time.Second).Format(http.TimeFormat)}, http.StatusOK},
+			{map[string]string{"If-Modified-Since": modtime.Format(http.TimeFormat), "If-None-Match": `"other"`}, http.StatusOK},
+			{map[string]string{"If-Modified-Since": "not a time"}, http.StatusOK},
+		}
+
+		for _, test := range tests {
+			code, header, _ := encode(t, "GET", test.headers, opts...)
+
+			if code != test.expected {
+				t.Fatal("Unexpected code", test.headers, code)
+			}
+
+			if header.Get("Last-Modified") != modtime.Format(http.TimeFormat) {
+				t.Fatal("Unexpected last modified", header.Get("Last-Modified"))
+			}
+		}
+	})
+
+	t.Run("EncodeErr", func(t *testing.T) {
+		opts := []httpu.Option{httpu.WithETagValue("v1"), httpu.WithLastModified(time.Now()), httpu.WithETag(httpu.ETagStrong)}
+		_, w, l, i, finish := newTestImpl(t, "", opts...)
+		defer finish()
+
+		l.EXPECT().Errorf(NonEmptyStr(), NonEmptyStr())
+
+		if i.EncodeJsonOr500(make(chan int), "encode") == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusInternalServerError || w.Header().Get("ETag") != "" || w.Header().Get("Last-Modified") != "" {
+			t.Fatal("Unexpected response", w.Code, w.Header())
+		}
+	})
+}
@@ -40,7 +40,7 @@ type dependency struct{}
 func (d *dependency) DoWork(r *Request) (*Result, error) { return new(Result), nil }
 func NewDependency() Dependency                          { return new(dependency) }
 
-var defs = []*di.Def{{NewDependency, di.PerHttpRequest}}
+var defs = []*di.Def{{Constructor: NewDependency, Lifetime: di.PerHttpRequest}}
 
 func MyHandler(dep Dependency, helper httpu.Impl) {
 	request := new(Request)
@@ -1,19 +1,56 @@
 package httpu
 
 import (
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/clavoie/logu/v2"
 )
 
+// ServeDownload writes content to the response as a file download with the given name. See
+// Impl.ServeDownload for details.
+func ServeDownload(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker) {
+	NewImpl(w, r, logu.NewGoLogger()).ServeDownload(name, modtime, content)
+}
+
+// ServeZip streams a zip archive of the entries returned from next to the response as a file
+// download with the given name. See Impl.ServeZip for details.
+func ServeZip(w http.ResponseWriter, name string, next ZipIterator, format string, args ...interface{}) bool {
+	return NewImpl(w, nil, logu.NewGoLogger()).ServeZip(name, next, format, args...)
+}
+
 // TryDecodeJsonFile attempts to parse a file upload from the request, and json deserialize
-// its contents into a destination object. If the multipart form cannot be parsed from the
-// request a HTTP 500 is written to the response and true is returned. If the file with the
-// given filename cannot be found, a HTTP 400 is written to the response and true is
-// returned. If the body of the file cannot be successfully json decoded into the destination
-// object, a HTTP 400 is written to the response and true is returned.
+// its contents into a destination object. If the multipart form is invalid a HTTP 400 is
+// written to the response and true is returned. If the form exceeds one of the limits set
+// by WithMultipartLimits with SetDefaultOptions a HTTP 413 is written instead, and if the
+// form cannot be stored a HTTP 500 is written. If the file with the given filename cannot be
+// found, a HTTP 400 is written to the response and true is returned. If the body of the file
+// cannot be successfully json decoded into the destination object, a HTTP 400 is written to
+// the response and true is returned. If the file is not allowed by the UploadPolicy set with
+// WithUploadPolicy, a HTTP 415 is written to the response and true is returned.
 //
 // If the entire operation is a success false is returned.
 func TryDecodeJsonFile(w http.ResponseWriter, r *http.Request, filename string, dst interface{}) bool {
 	return NewImpl(w, r, logu.NewGoLogger()).TryDecodeJsonFile(filename, dst)
 }
+
+// ForEachUpload parses the multipart form of the request, within the limits set by
+// WithMultipartLimits with SetDefaultOptions, and calls fn for each uploaded file. Files are
+// visited in order of their form field name, and then in the order they were uploaded under
+// that field. fn can stream the file wherever it needs to go with Upload.CopyTo or
+// Upload.Open.
+//
+// If the form cannot be parsed a HTTP 400, 413, or 500 is written to the response, the same
+// as TryDecodeJsonFile, and true is returned. If fn returns an error no more files are
+// visited, the error is written with WriteErr, and true is returned. The temporary files of
+// the form are always removed, and any error removing them is logged.
+//
+// If an UploadPolicy is set with WithUploadPolicy, each file is checked against it before fn
+// is called. A file that is not allowed stops the visit with a HTTP 415 written to the
+// response, and true is returned.
+//
+// If every file is visited successfully false is returned.
+func ForEachUpload(w http.ResponseWriter, r *http.Request, fn func(u *Upload) error, format string, args ...interface{}) bool {
+	return NewImpl(w, r, logu.NewGoLogger()).ForEachUpload(fn, format, args...)
+}
@@ -1,6 +1,6 @@
 module github.com/clavoie/httpu
 
-go 1.14
+go 1.18
 
 require (
 	github.com/clavoie/di/v2 v2.2.0
@@ -8,3 +8,9 @@ require (
 	github.com/clavoie/logu/v2 v2.1.0
 	github.com/golang/mock v1.4.3
 )
+
+require (
+	github.com/golang/protobuf v1.3.1 // indirect
+	golang.org/x/net v0.0.0-20200301022130-244492dfa37a // indirect
+	google.golang.org/appengine v1.6.5 // indirect
+)
@@ -0,0 +1,54 @@
+package httpu
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/clavoie/logu/v2"
+)
+
+// HandlerFunc is a typed request handler. It receives the decoded request body and returns
+// the response to encode, or an error to write with WriteErr.
+type HandlerFunc[Req, Resp any] func(ctx context.Context, req *Req) (*Resp, error)
+
+// Handle returns an http.Handler that runs fn through an Impl created with the given options,
+// logging to the go log package. See HandleDi for the steps taken on each request.
+func Handle[Req, Resp any](fn HandlerFunc[Req, Resp], opts ...Option) http.Handler {
+	handler := HandleDi(fn)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(NewImplWithOptions(w, r, logu.NewGoLogger(), opts...), w, r)
+	})
+}
+
+// HandleDi returns a handler func that can be passed to di.Resolver.HttpHandler, which
+// resolves the Impl, ResponseWriter, and Request of each request:
+//
+//	handler, err := resolver.HttpHandler(httpu.HandleDi(createWidget))
+//
+// For each request the body, if there is one, is decoded into a new Req with DecodeOr400.
+// fn is then called with the context of the request. An error returned from fn is written
+// with WriteErr, which maps it to a status code. Otherwise the response is encoded with
+// EncodeOr500, or a HTTP 204 is written if the response is nil.
+func HandleDi[Req, Resp any](fn HandlerFunc[Req, Resp]) func(Impl, http.ResponseWriter, *http.Request) {
+	return func(i Impl, w http.ResponseWriter, r *http.Request) {
+		req := new(Req)
+
+		if r.Body != nil && r.Body != http.NoBody && i.DecodeOr400(req, "Could not decode request to %v", r.URL.Path) {
+			return
+		}
+
+		resp, err := fn(r.Context(), req)
+
+		if i.WriteErr(err, "Could not handle request to %v", r.URL.Path) {
+			return
+		}
+
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		i.EncodeOr500(resp, "Could not encode response to %v", r.URL.Path)
+	}
+}
@@ -0,0 +1,92 @@
+package httpu_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clavoie/di/v2"
+	"github.com/clavoie/erru"
+	"github.com/clavoie/httpu"
+	"github.com/clavoie/logu/v2"
+)
+
+type greetRequest struct {
+	Name string
+}
+
+type greetResponse struct {
+	Greeting string
+}
+
+func greet(ctx context.Context, req *greetRequest) (*greetResponse, error) {
+	switch req.Name {
+	case "":
+		return nil, erru.NewHttpBadRequest("name is required")
+	case "nobody":
+		return nil, nil
+	}
+
+	return &greetResponse{"hello " + req.Name}, nil
+}
+
+func TestHandle(t *testing.T) {
+	handler := httpu.Handle(greet, httpu.WithStrictJson(true))
+
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+		response   string
+	}{
+		{"Success", `{"Name":"bob"}`, http.StatusOK, `{"Greeting":"hello bob"}`},
+		{"NoContent", `{"Name":"nobody"}`, http.StatusNoContent, ``},
+		{"HandlerErr", `{"Name":""}`, http.StatusBadRequest, ``},
+		{"DecodeErr", `{"Nome":"bob"}`, http.StatusBadRequest, `unknown field "Nome" at offset 1`},
+		{"NoBody", ``, http.StatusBadRequest, ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "http://test.com/greet", strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.statusCode {
+				t.Fatal("Unexpected code", w.Code)
+			}
+
+			if body := strings.TrimSpace(w.Body.String()); body != test.response {
+				t.Fatal("Unexpected body", body)
+			}
+		})
+	}
+
+	t.Run("HandleDi", func(t *testing.T) {
+		onErr := func(err *di.ErrResolve, w http.ResponseWriter, r *http.Request) {
+			t.Fatal(err.String())
+		}
+		loggerDefs := []*di.Def{{Constructor: logu.NewNullLogger, Lifetime: di.Singleton}}
+		resolver, err := di.NewResolver(onErr, loggerDefs, httpu.NewDiDefs())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handler, err := resolver.HttpHandler(httpu.HandleDi(greet))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("POST", "http://test.com/greet", strings.NewReader(`{"Name":"alice"}`))
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		if body := strings.TrimSpace(w.Body.String()); body != `{"Greeting":"hello alice"}` {
+			t.Fatal("Unexpected body", w.Code, body)
+		}
+	})
+}
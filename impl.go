@@ -2,8 +2,14 @@ package httpu
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/clavoie/logu/v2"
 )
@@ -13,58 +19,298 @@ type Impl interface {
 	// DecodeJsonOr400 attempts to json decode the request body into the destination object. See
 	// encoding/json for details.
 	//
-	// The request body is closed when this function returns.
+	// The request body is closed when this function returns. A body with a Content-Encoding,
+	// such as gzip, is decompressed with the Decompressor registered by WithDecompressor. If
+	// there is none a HTTP 415 is written to the response, along with an Accept-Encoding
+	// header listing the supported codings, and true is returned.
 	//
 	// If an error is encountered decoding the object a HTTP 400 is written to the response stream,
-	// and true is returned. If the decoding succeeds then false is returned
+	// and true is returned. If the request body is larger than the limit set by WithMaxBodyBytes
+	// a HTTP 413 is written instead. If the decoding succeeds then false is returned
 	DecodeJsonOr400(dst interface{}, format string, args ...interface{}) bool
 
+	// DecodeEachOr400 decodes the request body one item at a time, calling newItem for a
+	// pointer to decode each item into and then fn with the index of the item, starting at 0,
+	// and the item. Bodies with a Content-Type of application/x-ndjson, or a similar newline
+	// delimited json type, hold one item per line. Any other body must be a single json array
+	// of items. The body is read as the items are decoded, so it is never held in memory as a
+	// whole. DecodeEach wraps DecodeEachOr400 with a typed callback, and is usually simpler to
+	// use.
+	//
+	// The request body is closed when this function returns. Compressed bodies, the limit set
+	// by WithMaxBodyBytes, and WithStrictJson are handled the same way as DecodeJsonOr400, with
+	// strict json decoding checking each item for unknown fields and duplicate keys.
+	//
+	// If an item cannot be decoded a HTTP 400 is written to the response, with a detail that
+	// includes the index of the item, and true is returned. The decoding error itself is only
+	// logged, unless it is a *StrictJsonErr. With WithProblemDetails set the index is also
+	// written in the "index" member of the problem details document. If the body has more
+	// items than the limit set by WithMaxItems a HTTP 413 is written. If fn returns an error
+	// no more items are decoded, the error is written with WriteErr wrapped in an *ItemErr,
+	// and true is returned.
+	//
+	// If every item is decoded and handled false is returned.
+	DecodeEachOr400(newItem func() interface{}, fn func(index int, item interface{}) error, format string, args ...interface{}) bool
+
+	// DecodeOr400 decodes the request body into the destination object using the Decoder
+	// registered for the Content-Type of the request. See WithDecoder for the registered
+	// media types. Requests without a Content-Type are decoded as json.
+	//
+	// The request body is closed when this function returns.
+	//
+	// If no Decoder is registered for the Content-Type a HTTP 415 is written to the response,
+	// along with an Accept-Post or Accept-Patch header listing the supported media types, and
+	// true is returned. Otherwise DecodeOr400 works like DecodeJsonOr400.
+	DecodeOr400(dst interface{}, format string, args ...interface{}) bool
+
+	// DecodeAndValidateOr422 decodes the request body into the destination object with
+	// DecodeOr400, and then validates it with ValidateStruct. See ValidateStruct for the rules
+	// of the validate struct tag, and Validator for types that validate themselves.
+	//
+	// If any fields fail validation a HTTP 422 is written to the response, along with a json
+	// body listing the path, rule, and parameter of each failed field, and true is returned.
+	// With WithProblemDetails set, the fields are listed in the "errors" member of a problem
+	// details document instead. If the validate struct tags themselves are invalid a HTTP 500
+	// is written.
+	DecodeAndValidateOr422(dst interface{}, format string, args ...interface{}) bool
+
+	// DecodeJsonStrictOr400 works like DecodeJsonOr400 with strict json decoding turned on.
+	// See WithStrictJson for details.
+	DecodeJsonStrictOr400(dst interface{}, format string, args ...interface{}) bool
+
+	// EncodeAcceptedOr500 works like EncodeOr500, writing a HTTP 202 for work that has been
+	// accepted but not yet completed. If monitor is not empty the Location header is set to
+	// it, so that clients can poll a status monitor for the outcome of the work. src usually
+	// describes the status of the work.
+	//
+	// As the work has already been accepted, a request that accepts none of the registered
+	// media types is not answered with a HTTP 406. src is encoded with the first registered
+	// Encoder, application/json by default, instead.
+	EncodeAcceptedOr500(monitor string, src interface{}, format string, args ...interface{}) bool
+
+	// EncodeCreatedOr500 works like EncodeOr500, writing a HTTP 201 with the Location header
+	// set to location, the url of the created resource. src is usually the created resource.
+	// An empty location is logged as a warning along with ErrNoLocation, and the response is
+	// written without a Location header.
+	//
+	// As the resource has already been created, a request that accepts none of the
+	// registered media types is not answered with a HTTP 406. src is encoded with the first
+	// registered Encoder, application/json by default, instead.
+	EncodeCreatedOr500(location string, src interface{}, format string, args ...interface{}) bool
+
+	// EncodeOr500 encodes the src object into the response stream using the registered Encoder
+	// whose media type is most preferred by the Accept header of the request, including its
+	// q-values and wildcards. The Content-Type of the response is set to the media type of the
+	// Encoder, and Accept is added to the Vary header. See WithEncoder for the registered
+	// media types. If the request has no Accept header the first registered Encoder is used.
+	//
+	// If none of the registered media types are acceptable a HTTP 406 is written to the
+	// response. If there is any error encoding the object a HTTP 500 is written instead.
+	//
+	// Returns true if there was an error encountered, and false otherwise.
+	EncodeOr500(src interface{}, format string, args ...interface{}) bool
+
 	// EncodeJsonOr500 sets the Content-Type of the response to application/json, and encodes the
 	// src object into a json response stream. If there is any error encoding the object a
 	// HTTP 500 is returned instead.
 	//
+	// The encoded response is buffered, and written along with its Content-Length only once
+	// encoding succeeds. Responses larger than the size set by WithResponseBufferSize are
+	// streamed instead, in which case an error part way through can only be logged.
+	//
+	// With WithETag, WithETagValue, or WithLastModified set, a GET or HEAD request whose
+	// If-None-Match or If-Modified-Since header matches the response is answered with a HTTP
+	// 304 and no body. This is not an error, so false is returned.
+	//
+	// The status code and headers of a successful response can be set with WithStatusCode
+	// and WithHeader, usually for a single call with With:
+	//
+	//	i.With(httpu.WithStatusCode(http.StatusCreated)).EncodeJsonOr500(widget, "Could not encode")
+	//
 	// Returns true if there was an error encountered, and false otherwise.
 	EncodeJsonOr500(src interface{}, format string, args ...interface{}) bool
 
+	// EventStreamOr500 starts a stream of server-sent events in response to the request. The
+	// Content-Type of the response is set to text/event-stream, caching and proxy buffering
+	// are turned off, and the header is sent straight away. Each event sent on the returned
+	// EventStream is flushed to the client, and a heartbeat comment is sent at the interval
+	// set by WithHeartbeatInterval. The stream must be closed once the handler is done:
+	//
+	//	stream, failed := i.EventStreamOr500("Could not stream updates")
+	//	if failed {
+	//		return
+	//	}
+	//
+	//	defer stream.Close()
+	//
+	//	for update := range updates(stream.LastEventId()) {
+	//		if stream.Send(&httpu.Event{Id: update.Id, Data: update}) != nil {
+	//			return
+	//		}
+	//	}
+	//
+	// Once the client disconnects Send returns the error of the context of the request, and
+	// Done is closed. If the http.ResponseWriter cannot flush partial responses a HTTP 500 is
+	// written to the response and true is returned.
+	EventStreamOr500(format string, args ...interface{}) (*EventStream, bool)
+
+	// ForEachUpload parses the multipart form of the request, within the limits set by
+	// WithMultipartLimits, and calls fn for each uploaded file. Files are visited in order of
+	// their form field name, and then in the order they were uploaded under that field. fn
+	// can stream the file wherever it needs to go with Upload.CopyTo or Upload.Open.
+	//
+	// If the form cannot be parsed a HTTP 400, 413, or 500 is written to the response, the
+	// same as TryDecodeJsonFile, and true is returned. If fn returns an error no more files
+	// are visited, the error is written with WriteErr, and true is returned. The temporary
+	// files of the form are always removed, and any error removing them is logged.
+	//
+	// If an UploadPolicy is set with WithUploadPolicy, each file is checked against it before
+	// fn is called. A file that is not allowed stops the visit with a HTTP 415 written to the
+	// response, and true is returned.
+	//
+	// If every file is visited successfully false is returned.
+	ForEachUpload(fn func(u *Upload) error, format string, args ...interface{}) bool
+
+	// ServeDownload writes content to the response as a file download with the given name,
+	// using http.ServeContent. The Content-Disposition is set to an attachment with the name,
+	// and unless a Content-Type has already been set it is inferred from the extension of the
+	// name, or failing that by sniffing the content. Unless an ETag has already been set a
+	// strong ETag is made from the size of the content and modtime. If modtime is the zero
+	// time no ETag or Last-Modified header is written.
+	//
+	// Single and multiple range requests, with a HTTP 206 or 416, and conditional requests
+	// using If-None-Match, If-Modified-Since, and their counterparts are handled, as are HEAD
+	// requests. Interrupted downloads can be resumed with a range request and an If-Range or
+	// If-Match header holding the ETag. If the size of the content cannot be found a HTTP 500
+	// is written. Failures reading the content are logged as errors and failures writing the
+	// response, such as a client hanging up, are logged as warnings.
+	ServeDownload(name string, modtime time.Time, content io.ReadSeeker)
+
+	// ServeZip streams a zip archive of the entries returned from next to the response as a
+	// file download with the given name. The archive is written as the entries are read, so
+	// it is never held in memory as a whole. next is called until it returns io.EOF.
+	//
+	// If next, or reading an entry, fails before any of the archive has been written, a HTTP
+	// 500 is written to the response, the message and error are logged, and true is
+	// returned. Once part of the archive has been sent the status can no longer change, so a
+	// failure is logged and the handler is aborted by panicking with http.ErrAbortHandler.
+	// This closes the connection instead of sending a truncated archive that looks complete.
+	//
+	// If the whole archive is written false is returned.
+	ServeZip(name string, next ZipIterator, format string, args ...interface{}) bool
+
 	// SetAsDownloadFileWithName sets the Content-Disposition of the response writer to that of
-	// an attachment with the specified file name.
+	// an attachment with the specified file name. The file name is escaped as described by
+	// ContentDisposition.
 	SetAsDownloadFileWithName(filenameFmt string, args ...interface{})
 
+	// SetDispositionWithName sets the Content-Disposition of the response writer to the
+	// disposition type, inline or attachment, with the specified file name. The file name is
+	// escaped as described by ContentDisposition.
+	SetDispositionWithName(disposition Disposition, filenameFmt string, args ...interface{})
+
+	// StreamJsonArrayOr500 streams the items returned from next to the response as the
+	// elements of a single json array, with a Content-Type of application/json. Items are
+	// json encoded and written as they are returned, so the whole array is never held in
+	// memory, and are flushed to the client at least as often as the interval set by
+	// WithStreamFlushInterval. next is called until it returns io.EOF, or until the context of
+	// the request is done. StreamChan adapts a channel to a StreamIterator.
+	//
+	// If next, or encoding an item, fails before any of the response has been sent a HTTP 500
+	// is written to the response. Once part of the response has been sent the status can no
+	// longer change, so the array is left unterminated and the StreamErrTrailer trailer is set
+	// instead. Either way the message and error are logged and true is returned. If the
+	// context of the request is done, or the response cannot be written, the message and
+	// error are logged as a warning and true is returned.
+	//
+	// If every item is streamed false is returned.
+	StreamJsonArrayOr500(next StreamIterator, format string, args ...interface{}) bool
+
+	// StreamNdjsonOr500 works like StreamJsonArrayOr500, streaming the items as newline
+	// delimited json, one item per line, with a Content-Type of application/x-ndjson.
+	StreamNdjsonOr500(next StreamIterator, format string, args ...interface{}) bool
+
 	// TryDecodeJsonFile attempts to parse a file upload from the request, and json deserialize
-	// its contents into a destination object. If the multipart form cannot be parsed from the
-	// request a HTTP 500 is written to the response and true is returned. If the file with the
-	// given filename cannot be found, a HTTP 400 is written to the response and true is
-	// returned. If the body of the file cannot be successfully json decoded into the destination
-	// object, a HTTP 400 is written to the response and true is returned.
+	// its contents into a destination object. If the multipart form is invalid a HTTP 400 is
+	// written to the response and true is returned. If the form exceeds one of the limits set
+	// by WithMultipartLimits a HTTP 413 is written instead, and if the form cannot be stored
+	// a HTTP 500 is written. If the file with the given filename cannot be found, a HTTP 400
+	// is written to the response and true is returned. If the body of the file cannot be
+	// successfully json decoded into the destination object, a HTTP 400 is written to the
+	// response and true is returned. If the file is not allowed by the UploadPolicy set with
+	// WithUploadPolicy, a HTTP 415 is written to the response and true is returned.
+	//
+	// With WithMultipartStreaming set the file is decoded as it is read from the request,
+	// without storing the form in memory or in temporary files first.
 	//
 	// If the entire operation is a success false is returned.
 	TryDecodeJsonFile(filename string, dst interface{}) bool
 
+	// VerifySignedURL verifies the url of the request with the signer, using the bound values
+	// the url was signed with. If the signature is missing or invalid a HTTP 403 is written
+	// to the response, if the url has expired a HTTP 410 is written, and in both cases the
+	// message and error are logged as a warning and true is returned. If an ErrMap set with
+	// WithErrMap maps the errors, its status codes are written instead.
+	//
+	// If the url is verified false is returned.
+	VerifySignedURL(signer *URLSigner, bound url.Values, format string, args ...interface{}) bool
+
+	// With returns a copy of this Impl with the options applied on top of its own. The
+	// original Impl is not modified, which allows options to be set for a single call:
+	//
+	//	i.With(httpu.WithMaxBodyBytes(1024)).DecodeJsonOr400(dst, "Could not decode")
+	With(opts ...Option) Impl
+
 	// Write400IfErr works like WriteIfErr(err, http.StatusBadRequest, format, args...)
 	Write400IfErr(err error, format string, args ...interface{}) bool
 
 	// Write500IfErr works like WriteIfErr(err, http.StatusInternalServerError, format, args...)
 	Write500IfErr(err error, format string, args ...interface{}) bool
 
+	// WriteErr works like WriteIfErr, deriving the status code from the error. If an ErrMap
+	// is set with WithErrMap and one of its mappings matches the error, its status code is
+	// written along with its public message. Otherwise the chain of wrapped errors is
+	// searched with errors.As for an error with a StatusCode() int method, such as
+	// erru.HttpErr. If there is none, or its status code is not a 4xx or 5xx status,
+	// http.StatusInternalServerError is written. The message and error are logged as a
+	// warning or an error depending on the status code, the same way WriteIfErr logs them.
+	WriteErr(err error, format string, args ...interface{}) bool
+
 	// WriteIfErr writes an http status code and message if there is an error. If the error
 	// is non-nil then the given http status code is written to the http.ResponseWriter,
-	// and the message and error are written to the go log package.
+	// and the message and error are written to the go log package. If WithProblemDetails
+	// is set an application/problem+json body describing the status code is written as
+	// well. The message and error are never written to the response.
 	//
 	// true is returned if an error was detected and false is returned if there is no error
 	WriteIfErr(err error, statusCode int, format string, args ...interface{}) bool
+
+	// WriteProblemIfErr works like WriteIfErr, writing p to the response as an
+	// application/problem+json document whether or not WithProblemDetails is set. The
+	// status code written is p.Status, or http.StatusInternalServerError if it is 0. The
+	// message and error are written to the log only, keeping them separate from the details
+	// sent to the client.
+	WriteProblemIfErr(err error, p *Problem, format string, args ...interface{}) bool
 }
 
 // impl is an implementation of Impl
 type impl struct {
 	l logu.Logger
+	o *options
 	r *http.Request
 	w http.ResponseWriter
 }
 
 // NewImpl returns a new instance of Impl
 func NewImpl(w http.ResponseWriter, r *http.Request, l logu.Logger) Impl {
+	return NewImplWithOptions(w, r, l)
+}
+
+// NewImplWithOptions returns a new instance of Impl configured with the given options
+func NewImplWithOptions(w http.ResponseWriter, r *http.Request, l logu.Logger, opts ...Option) Impl {
 	return &impl{
 		l: l,
+		o: newOptions(opts...),
 		r: r,
 		w: w,
 	}
@@ -73,50 +319,275 @@ func NewImpl(w http.ResponseWriter, r *http.Request, l logu.Logger) Impl {
 func (i *impl) DecodeJsonOr400(dst interface{}, format string, args ...interface{}) bool {
 	defer i.r.Body.Close()
 
-	decoder := json.NewDecoder(i.r.Body)
-	err := decoder.Decode(dst)
+	return i.decodeOr400(jsonDecoder{}, dst, format, args...)
+}
+
+func (i *impl) DecodeOr400(dst interface{}, format string, args ...interface{}) bool {
+	defer i.r.Body.Close()
+
+	mediaType := "application/json"
+	contentType := i.r.Header.Get("Content-Type")
+	var err error
+
+	if contentType != "" {
+		mediaType, _, err = mime.ParseMediaType(contentType)
+	}
+
+	decoder, hasDecoder := i.o.decoders.find(mediaType)
+
+	if err != nil || hasDecoder == false {
+		acceptHeader := "Accept-Post"
+		if i.r.Method == http.MethodPatch {
+			acceptHeader = "Accept-Patch"
+		}
+
+		i.w.Header().Set(acceptHeader, strings.Join(i.o.decoders.mediaTypes(), ", "))
+		err = fmt.Errorf("%w: %v", ErrUnsupportedMediaType, contentType)
+		return i.WriteIfErr(err, http.StatusUnsupportedMediaType, format, args...)
+	}
+
+	return i.decodeOr400(decoder, dst, format, args...)
+}
+
+// decodeOr400 decodes the request body into dst with decoder, writing a HTTP 400 or 413
+// to the response if there is an error
+func (i *impl) decodeOr400(decoder Decoder, dst interface{}, format string, args ...interface{}) bool {
+	body, statusCode, err := i.requestBody(i.o.maxBodyBytes)
+
+	if i.WriteIfErr(err, statusCode, format, args...) {
+		return true
+	}
+
+	defer body.Close()
+
+	if _, isJson := decoder.(jsonDecoder); isJson && i.o.strictJson {
+		err = decodeStrictJson(body, dst)
+	} else {
+		err = decoder.Decode(body, dst)
+	}
+
+	if errors.Is(err, ErrBodyTooLarge) {
+		return i.WriteIfErr(err, http.StatusRequestEntityTooLarge, format, args...)
+	}
+
+	var strictErr *StrictJsonErr
+	if errors.As(err, &strictErr) {
+		return i.writeErr(err, http.StatusBadRequest, &Problem{Detail: strictErr.Error()}, format, args...)
+	}
 
 	return i.Write400IfErr(err, format, args...)
 }
 
-func (i *impl) EncodeJsonOr500(src interface{}, format string, args ...interface{}) bool {
+func (i *impl) DecodeAndValidateOr422(dst interface{}, format string, args ...interface{}) bool {
+	if i.DecodeOr400(dst, format, args...) {
+		return true
+	}
+
+	err := ValidateStruct(dst)
+
+	var validationErrs ValidationErrs
+	if errors.As(err, &validationErrs) == false {
+		return i.Write500IfErr(err, format, args...)
+	}
+
+	statusCode := http.StatusUnprocessableEntity
+
+	if i.o.problemDetails {
+		p := &Problem{
+			Detail:     "The request body failed validation",
+			Extensions: map[string]interface{}{"errors": validationErrs},
+		}
+
+		return i.writeErr(err, statusCode, p, format, args...)
+	}
+
+	data, marshalErr := json.Marshal(map[string]interface{}{"errors": validationErrs})
+
+	if marshalErr != nil {
+		return i.Write500IfErr(marshalErr, format, args...)
+	}
+
 	i.w.Header().Set("Content-Type", "application/json")
+	i.w.WriteHeader(statusCode)
+	i.w.Write(data)
+	i.logErr(err, statusCode, format, args...)
+	return true
+}
 
-	encoder := json.NewEncoder(i.w)
-	err := encoder.Encode(src)
+func (i *impl) DecodeJsonStrictOr400(dst interface{}, format string, args ...interface{}) bool {
+	return i.With(WithStrictJson(true)).DecodeJsonOr400(dst, format, args...)
+}
 
-	return i.Write500IfErr(err, format, args...)
+func (i *impl) EncodeOr500(src interface{}, format string, args ...interface{}) bool {
+	return i.encodeNegotiatedOr500(false, src, format, args...)
 }
 
-func (i *impl) TryDecodeJsonFile(filename string, dst interface{}) bool {
-	err := i.r.ParseMultipartForm(10000000)
+// encodeNegotiatedOr500 encodes src with the Encoder the Accept header of the request
+// prefers. If the request accepts none of them a HTTP 406 is written, unless fallback is true
+// and there is an Encoder registered, in which case the first registered Encoder is used.
+func (i *impl) encodeNegotiatedOr500(fallback bool, src interface{}, format string, args ...interface{}) bool {
+	addVary(i.w.Header(), "Accept")
 
-	if i.Write500IfErr(err, "Could not parse multipart form for file %v", filename) {
-		return true
+	var accept string
+	if i.r != nil {
+		accept = i.r.Header.Get("Accept")
 	}
 
-	defer func() {
-		err := i.r.MultipartForm.RemoveAll()
+	index := negotiate(accept, i.o.encoders.mediaTypes())
 
-		if err != nil {
-			i.l.Errorf("%v", err)
+	if index < 0 && fallback && len(i.o.encoders) > 0 {
+		index = 0
+	}
+
+	if index < 0 {
+		err := fmt.Errorf("%w: %v", ErrNotAcceptable, accept)
+		return i.WriteIfErr(err, http.StatusNotAcceptable, format, args...)
+	}
+
+	entry := i.o.encoders[index]
+	return i.encodeOr500(entry.mediaType, entry.encoder, src, format, args...)
+}
+
+func (i *impl) EncodeAcceptedOr500(monitor string, src interface{}, format string, args ...interface{}) bool {
+	opts := []Option{WithStatusCode(http.StatusAccepted)}
+
+	if monitor != "" {
+		opts = append(opts, WithHeader("Location", monitor))
+	}
+
+	return i.With(opts...).(*impl).encodeNegotiatedOr500(true, src, format, args...)
+}
+
+func (i *impl) EncodeCreatedOr500(location string, src interface{}, format string, args ...interface{}) bool {
+	if location == "" {
+		i.logErr(ErrNoLocation, http.StatusCreated, format, args...)
+	}
+
+	opts := []Option{WithStatusCode(http.StatusCreated), WithHeader("Location", location)}
+	return i.With(opts...).(*impl).encodeNegotiatedOr500(true, src, format, args...)
+}
+
+func (i *impl) EncodeJsonOr500(src interface{}, format string, args ...interface{}) bool {
+	return i.encodeOr500("application/json", EncoderFunc(encodeJson), src, format, args...)
+}
+
+// encodeOr500 sets the Content-Type of the response to mediaType and encodes src into the
+// response with encoder. The response is buffered so that a HTTP 500 can be written if there
+// is an error, unless it grows past the size set by WithResponseBufferSize. Once a response
+// is streaming an error can only be logged.
+func (i *impl) encodeOr500(mediaType string, encoder Encoder, src interface{}, format string, args ...interface{}) bool {
+	var w http.ResponseWriter = i.w
+	var cw *compressWriter
+
+	if i.o.compression {
+		cw = newCompressWriter(i.w, i.r, i.o)
+		defer cw.Close()
+		w = cw
+	}
+
+	conditional := i.o.statusCode == 0 || i.o.statusCode == http.StatusOK
+	i.setValidators()
+
+	if conditional && i.notModified() {
+		if cw != nil {
+			cw.weakenNotModified()
 		}
-	}()
-	file, _, err := i.r.FormFile(filename)
+
+		i.writeNotModified()
+		return false
+	}
+
+	i.w.Header().Set("Content-Type", mediaType)
+	buf := newResponseBuffer(w, i.o.responseBufferSize)
+	buf.header = i.o.headers
+	buf.statusCode = i.o.statusCode
+	defer buf.release()
+
+	err := encoder.Encode(buf, src)
+
+	if err != nil && buf.streaming == false {
+		// the validators describe the response that failed to encode, not the error
+		header := i.w.Header()
+		header.Del("Content-Type")
+		header.Del("ETag")
+		header.Del("Last-Modified")
+		return i.Write500IfErr(err, format, args...)
+	}
+
+	if err == nil && buf.streaming == false && i.o.etagMode != ETagOff && i.o.etag == "" {
+		i.w.Header().Set("ETag", hashETag(i.o.etagMode, buf.buf.Bytes()))
+
+		if conditional && i.notModified() {
+			if cw != nil {
+				cw.weakenNotModified()
+			}
+
+			i.writeNotModified()
+			return false
+		}
+	}
+
+	if err == nil {
+		err = buf.commit()
+	}
+
+	if err != nil {
+		i.logErr(err, http.StatusInternalServerError, format, args...)
+		return true
+	}
+
+	return false
+}
+
+func (i *impl) TryDecodeJsonFile(filename string, dst interface{}) bool {
+	if i.o.multipartStreaming {
+		return i.streamJsonFile(filename, dst)
+	}
+
+	statusCode, err := i.parseMultipartForm()
+
+	if i.WriteIfErr(err, statusCode, "Could not parse multipart form for file %v", filename) {
+		return true
+	}
+
+	defer i.removeMultipartForm()
+	file, fileHeader, err := i.r.FormFile(filename)
 
 	if i.Write400IfErr(err, "Could not find file with name %v in upload", filename) {
 		return true
 	}
 
 	defer file.Close()
-	decoder := json.NewDecoder(file)
+	reader, err := i.o.uploadPolicy.checkUpload(fileHeader.Filename, fileHeader.Header, file)
+
+	if i.WriteIfErr(err, multipartErrStatusCode(err), "Upload not allowed for file %v", filename) {
+		return true
+	}
+
+	decoder := json.NewDecoder(reader)
 	err = decoder.Decode(dst)
 
 	return i.Write400IfErr(err, "Could not decode json from file %v", filename)
 }
 
 func (i *impl) SetAsDownloadFileWithName(filenameFmt string, args ...interface{}) {
-	i.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v\"", fmt.Sprintf(filenameFmt, args...)))
+	i.SetDispositionWithName(DispositionAttachment, filenameFmt, args...)
+}
+
+func (i *impl) SetDispositionWithName(disposition Disposition, filenameFmt string, args ...interface{}) {
+	i.w.Header().Set("Content-Disposition", ContentDisposition(disposition, fmt.Sprintf(filenameFmt, args...)))
+}
+
+func (i *impl) With(opts ...Option) Impl {
+	o := *i.o
+	o.apply(opts...)
+
+	return &impl{
+		l: i.l,
+		o: &o,
+		r: i.r,
+		w: i.w,
+	}
 }
 
 func (i *impl) Write400IfErr(err error, format string, args ...interface{}) bool {
@@ -127,19 +598,87 @@ func (i *impl) Write500IfErr(err error, format string, args ...interface{}) bool
 	return i.WriteIfErr(err, http.StatusInternalServerError, format, args...)
 }
 
+func (i *impl) WriteErr(err error, format string, args ...interface{}) bool {
+	if err == nil {
+		return false
+	}
+
+	statusCode, p := resolveErr(i.o.errMap, err)
+	return i.writeErr(err, statusCode, p, format, args...)
+}
+
 func (i *impl) WriteIfErr(err error, statusCode int, format string, args ...interface{}) bool {
-	logFn := i.l.Warningf
+	return i.writeErr(err, statusCode, nil, format, args...)
+}
 
-	if statusCode >= 500 {
-		logFn = i.l.Errorf
+func (i *impl) WriteProblemIfErr(err error, p *Problem, format string, args ...interface{}) bool {
+	if err == nil {
+		return false
+	}
+
+	statusCode := http.StatusInternalServerError
+	if p != nil && p.Status != 0 {
+		statusCode = p.Status
 	}
 
+	i.writeStatus(statusCode, p, true)
+	i.logErr(err, statusCode, format, args...)
+	return true
+}
+
+// writeErr works like WriteIfErr, additionally using p, which may be nil, for the public
+// details of the error. See writeStatus.
+func (i *impl) writeErr(err error, statusCode int, p *Problem, format string, args ...interface{}) bool {
 	if err == nil {
 		return false
 	}
 
+	i.writeStatus(statusCode, p, i.o.problemDetails)
+	i.logErr(err, statusCode, format, args...)
+	return true
+}
+
+// writeStatus writes statusCode to the response. If asProblem is true the body of the
+// response is a problem details document built from p. Otherwise the Detail of p, if there
+// is one, is written as plain text.
+func (i *impl) writeStatus(statusCode int, p *Problem, asProblem bool) {
+	header := i.w.Header()
+
+	if asProblem {
+		data, err := json.Marshal(p.complete(statusCode, i.r))
+
+		if err == nil {
+			header.Set("Content-Type", "application/problem+json")
+			header.Set("X-Content-Type-Options", "nosniff")
+			i.w.WriteHeader(statusCode)
+			i.w.Write(data)
+			return
+		}
+
+		i.l.Errorf("Could not encode problem details: %v", err)
+	}
+
+	detail := p.detail()
+	if detail == "" {
+		i.w.WriteHeader(statusCode)
+		return
+	}
+
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("X-Content-Type-Options", "nosniff")
 	i.w.WriteHeader(statusCode)
+	fmt.Fprintln(i.w, detail)
+}
+
+// logErr writes the message and error to the log, as an error if statusCode is a 5xx
+// status and as a warning otherwise
+func (i *impl) logErr(err error, statusCode int, format string, args ...interface{}) {
+	logFn := i.l.Warningf
+
+	if statusCode >= 500 {
+		logFn = i.l.Errorf
+	}
+
 	args = append(args, err)
 	logFn(format+": %v", args...)
-	return true
 }
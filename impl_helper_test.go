@@ -0,0 +1,25 @@
+package httpu_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clavoie/httpu"
+	mock_v2 "github.com/clavoie/logu/v2/mock_logu"
+	"github.com/golang/mock/gomock"
+)
+
+// newTestImpl returns a POST request with the given body, a response recorder, a mock logger,
+// and an Impl created from them with the given options. The returned func finishes the mock
+// controller.
+func newTestImpl(t *testing.T, body string, opts ...httpu.Option) (*http.Request, *httptest.ResponseRecorder, *mock_v2.MockLogger, httpu.Impl, func()) {
+	r := httptest.NewRequest("POST", "http://test.com/test", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	ctrl := gomock.NewController(t)
+	l := mock_v2.NewMockLogger(ctrl)
+	i := httpu.NewImplWithOptions(w, r, l, opts...)
+
+	return r, w, l, i, ctrl.Finish
+}
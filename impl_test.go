@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -74,6 +76,242 @@ func TestImpl(t *testing.T) {
 		}
 	})
 
+	t.Run("DecodeJsonOr400MaxBodyBytes", func(t *testing.T) {
+		_, w, _, i, finish := newImpl(successfulJson, t)
+		defer finish()
+
+		j := new(Json)
+		if i.With(httpu.WithMaxBodyBytes(int64(len(successfulJson)))).DecodeJsonOr400(j, format, formatArgs...) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if j.Field != fieldValue || w.Code != http.StatusOK {
+			t.Fatal(j.Field, w.Code)
+		}
+	})
+	t.Run("DecodeJsonOr400ContentLengthTooLarge", func(t *testing.T) {
+		_, w, l, i, finish := newImpl(successfulJson, t)
+		defer finish()
+
+		l.EXPECT().Warningf(errFormat, newErrArgs(httpu.ErrBodyTooLarge)...)
+
+		j := new(Json)
+		if i.With(httpu.WithMaxBodyBytes(2)).DecodeJsonOr400(j, format, formatArgs...) == false {
+			t.Fatal("Expecting to fail on content length")
+		}
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("Unexpected code: %v", w.Code)
+		}
+
+		if j.Field != 0 {
+			t.Fatal("Was not expecting the body to be read")
+		}
+	})
+	t.Run("DecodeJsonOr400BodyTooLarge", func(t *testing.T) {
+		r, w, l, i, finish := newImpl(successfulJson, t)
+		defer finish()
+
+		r.ContentLength = -1
+		l.EXPECT().Warningf(errFormat, newErrArgs(httpu.ErrBodyTooLarge)...)
+
+		j := new(Json)
+		if i.With(httpu.WithMaxBodyBytes(4)).DecodeJsonOr400(j, format, formatArgs...) == false {
+			t.Fatal("Expecting to fail on body size")
+		}
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("Unexpected code: %v", w.Code)
+		}
+	})
+	t.Run("DecodeOr400", func(t *testing.T) {
+		type Form struct {
+			Field  int      `xml:"field" form:"field"`
+			Names  []string `form:"name"`
+			Active bool
+			Skip   string `form:"-"`
+		}
+
+		tests := []struct {
+			name        string
+			contentType string
+			body        string
+		}{
+			{"Default", "", `{"Field":100}`},
+			{"Json", "application/json; charset=utf-8", `{"Field":100}`},
+			{"JsonSuffix", "application/vnd.test+json", `{"Field":100}`},
+			{"Xml", "application/xml", `<Form><field>100</field></Form>`},
+			{"XmlSuffix", "application/atom+xml", `<Form><field>100</field></Form>`},
+			{"Form", "application/x-www-form-urlencoded", `field=100&name=a&name=b&Active=true&Skip=x`},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				r, w, _, i, finish := newImpl(test.body, t)
+				defer finish()
+
+				r.Header.Set("Content-Type", test.contentType)
+				f := new(Form)
+
+				if i.DecodeOr400(f, format, formatArgs...) {
+					t.Fatal("Was not expecting an error", w.Code)
+				}
+
+				if f.Field != fieldValue || f.Skip != "" {
+					t.Fatal("Unexpected decode", f)
+				}
+			})
+		}
+
+		r, _, _, i, finish := newImpl(`field=1&name=a&name=b&Active=true`, t)
+		defer finish()
+
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		f := new(Form)
+
+		if i.DecodeOr400(f, format, formatArgs...) || f.Active == false || strings.Join(f.Names, ",") != "a,b" {
+			t.Fatal("Unexpected form decode", f)
+		}
+	})
+	t.Run("DecodeOr400CustomDecoder", func(t *testing.T) {
+		r, _, _, i, finish := newImpl(`hello`, t)
+		defer finish()
+
+		r.Header.Set("Content-Type", "text/plain")
+		decoder := httpu.DecoderFunc(func(r io.Reader, dst interface{}) error {
+			data, err := ioutil.ReadAll(r)
+			*dst.(*string) = string(data)
+			return err
+		})
+
+		var s string
+		if i.With(httpu.WithDecoder("Text/Plain", decoder)).DecodeOr400(&s, format, formatArgs...) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if s != "hello" {
+			t.Fatal("Unexpected decode", s)
+		}
+	})
+	t.Run("DecodeOr400UnsupportedMediaType", func(t *testing.T) {
+		tests := []struct {
+			method string
+			header string
+			opts   []httpu.Option
+		}{
+			{http.MethodPost, "Accept-Post", nil},
+			{http.MethodPatch, "Accept-Patch", nil},
+			{http.MethodPost, "Accept-Post", []httpu.Option{httpu.WithDecoder("application/xml", nil)}},
+		}
+
+		for _, test := range tests {
+			r, w, l, i, finish := newImpl(`<Form/>`, t)
+
+			r.Method = test.method
+			r.Header.Set("Content-Type", "application/xml")
+			l.EXPECT().Warningf(errFormat, formatArgs[0], NonEmptyStr())
+
+			if test.opts == nil {
+				r.Header.Set("Content-Type", "application/msgpack")
+			}
+
+			if i.With(test.opts...).DecodeOr400(new(Json), format, formatArgs...) == false {
+				t.Fatal("Was expecting an error")
+			}
+
+			if w.Code != http.StatusUnsupportedMediaType {
+				t.Fatalf("Unexpected code: %v", w.Code)
+			}
+
+			accept := w.Header().Get(test.header)
+			if strings.Contains(accept, "application/json") == false {
+				t.Fatal("Unexpected accept header", accept)
+			}
+
+			if test.opts != nil && strings.Contains(accept, "application/xml") {
+				t.Fatal("Was not expecting xml to be accepted", accept)
+			}
+
+			finish()
+		}
+	})
+	t.Run("DecodeJsonStrictOr400", func(t *testing.T) {
+		_, w, _, i, finish := newImpl(` {"Field":100}
+`, t)
+		defer finish()
+
+		j := new(Json)
+		if i.DecodeJsonStrictOr400(j, format, formatArgs...) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if j.Field != fieldValue || w.Code != http.StatusOK {
+			t.Fatal(j.Field, w.Code)
+		}
+	})
+	t.Run("DecodeJsonStrictOr400Fail", func(t *testing.T) {
+		type Nested struct {
+			Items []Json
+		}
+
+		tests := []struct {
+			name     string
+			body     string
+			expected string
+		}{
+			{"UnknownField", `{"Items":[{"Field":1,"Feild":2}]}`, `unknown field "Items[0].Feild" at offset 21`},
+			{"UnknownFieldWhitespace", `{ "Items": [ ], "Extra": 1}`, `unknown field "Extra" at offset 16`},
+			{"DuplicateKey", `{"Items":[{"Field":1},{"Field":2,"Field":3}]}`, `duplicate key "Items[1].Field" at offset 33`},
+			{"DuplicateKeyCase", `{"Items":[{"Field":1,"field":2}]}`, `duplicate key "Items[0].field" at offset 21`},
+			{"TrailingData", `{"Items":[]} {"Items":[]}`, `unexpected data after json value at offset 12`},
+			{"TrailingGarbage", `{"Items":[]}x`, `unexpected data after json value at offset 12`},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, w, l, i, finish := newImpl(test.body, t)
+				defer finish()
+
+				var logged error
+				l.EXPECT().Warningf(errFormat, formatArgs[0], gomock.Any()).Do(func(format string, args ...interface{}) {
+					logged = args[1].(error)
+				})
+
+				if i.DecodeJsonStrictOr400(new(Nested), format, formatArgs...) == false {
+					t.Fatal("Expecting to fail on decode")
+				}
+
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("Unexpected code: %v", w.Code)
+				}
+
+				if strings.Contains(logged.Error(), test.expected) == false {
+					t.Fatal("Unexpected log: ", logged)
+				}
+
+				if strings.Contains(w.Body.String(), test.expected) == false {
+					t.Fatal("Unexpected body: ", w.Body.String())
+				}
+			})
+		}
+	})
+	t.Run("With", func(t *testing.T) {
+		r, w, l, _, finish := newImpl(successfulJson, t)
+		defer finish()
+
+		i := httpu.NewImplWithOptions(w, r, l, httpu.WithMaxBodyBytes(2))
+		if i.With(httpu.WithMaxBodyBytes(0)).DecodeJsonOr400(new(Json), format, formatArgs...) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		l.EXPECT().Warningf(errFormat, newErrArgs(httpu.ErrBodyTooLarge)...)
+		r.Body = ioutil.NopCloser(strings.NewReader(successfulJson))
+
+		if i.DecodeJsonOr400(new(Json), format, formatArgs...) == false {
+			t.Fatal("Was expecting the original limit to be kept")
+		}
+	})
+
 	t.Run("EncodeJsonOr500", func(t *testing.T) {
 		_, w, _, i, finish := newImpl(``, t)
 		defer finish()
@@ -88,6 +326,83 @@ func TestImpl(t *testing.T) {
 			t.Fatal("Was expecting: ", successfulJson, actualJ)
 		}
 	})
+	t.Run("EncodeOr500", func(t *testing.T) {
+		type Row struct {
+			Field int    `xml:"field" csv:"field"`
+			Name  string `csv:"name"`
+			Skip  string `csv:"-"`
+		}
+
+		tests := []struct {
+			accept      string
+			contentType string
+			body        string
+		}{
+			{"", "application/json", `[{"Field":100,"Name":"a","Skip":"b"}]`},
+			{"application/json", "application/json", `[{"Field":100,"Name":"a","Skip":"b"}]`},
+			{"text/html, */*;q=0.1", "application/json", `[{"Field":100,"Name":"a","Skip":"b"}]`},
+			{"text/*", "text/csv", "field,name\n100,a"},
+			{"application/json;q=0.5, text/csv", "text/csv", "field,name\n100,a"},
+			{"application/*;q=0.8, application/json;q=0.2", "application/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<Row><field>100</field><Name>a</Name><Skip>b</Skip></Row>"},
+			{"*/*;q=0.5, application/json;q=0", "application/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<Row><field>100</field><Name>a</Name><Skip>b</Skip></Row>"},
+		}
+
+		for _, test := range tests {
+			r, w, _, i, finish := newImpl(``, t)
+
+			r.Header.Set("Accept", test.accept)
+			if i.EncodeOr500([]Row{{fieldValue, "a", "b"}}, format, formatArgs...) {
+				t.Fatal("Was not expecting an error", test.accept)
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != test.contentType {
+				t.Fatal("Unexpected content type", test.accept, contentType)
+			}
+
+			if body := strings.TrimSpace(w.Body.String()); body != test.body {
+				t.Fatal("Unexpected body", test.accept, body)
+			}
+
+			if w.Header().Get("Vary") != "Accept" {
+				t.Fatal("Was expecting a Vary header", w.Header())
+			}
+
+			finish()
+		}
+	})
+	t.Run("EncodeOr500CustomEncoder", func(t *testing.T) {
+		r, w, _, i, finish := newImpl(``, t)
+		defer finish()
+
+		encoder := httpu.EncoderFunc(func(w io.Writer, src interface{}) error {
+			_, err := fmt.Fprintf(w, "value: %v", src)
+			return err
+		})
+
+		r.Header.Set("Accept", "text/plain, application/json;q=0.9")
+		if i.With(httpu.WithEncoder("text/plain", encoder)).EncodeOr500(fieldValue, format, formatArgs...) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Header().Get("Content-Type") != "text/plain" || w.Body.String() != "value: 100" {
+			t.Fatal("Unexpected response", w.Header(), w.Body.String())
+		}
+	})
+	t.Run("EncodeOr500NotAcceptable", func(t *testing.T) {
+		r, w, l, i, finish := newImpl(``, t)
+		defer finish()
+
+		l.EXPECT().Warningf(errFormat, formatArgs[0], NonEmptyStr())
+		r.Header.Set("Accept", "application/json")
+
+		if i.With(httpu.WithEncoder("application/json", nil)).EncodeOr500(fieldValue, format, formatArgs...) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusNotAcceptable || w.Header().Get("Vary") != "Accept" {
+			t.Fatal("Unexpected response", w.Code, w.Header())
+		}
+	})
 	t.Run("EncodeJsonOr500Fail", func(t *testing.T) {
 		_, w, l, i, finish := newImpl(``, t)
 		defer finish()
@@ -231,15 +546,15 @@ Content-Transfer-Encoding: binary
 		_, w, l, i, finish := newImpl(``, t)
 		defer finish()
 
-		l.EXPECT().Errorf(NonEmptyStr(), filename)
+		l.EXPECT().Warningf(NonEmptyStr(), filename, NonEmptyStr())
 		j := new(Json)
 
 		if i.TryDecodeJsonFile(filename, j) == false {
 			t.Fatal("Was expecting parse failure")
 		}
 
-		if w.Code != 500 {
-			t.Fatal("Was expecting 500 status code")
+		if w.Code != 400 {
+			t.Fatal("Was expecting 400 status code")
 		}
 	})
 	t.Run("TryDecodeJsonFileFileNotFound", func(t *testing.T) {
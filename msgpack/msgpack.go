@@ -0,0 +1,401 @@
+// Package msgpack provides an httpu.Encoder that writes responses as MessagePack. It is a
+// separate package so that applications which do not need MessagePack do not have to import
+// it, and it only depends on the standard library:
+//
+//	httpu.NewDiDefs(msgpack.WithEncoder())
+//
+// Only encoding is supported.
+package msgpack
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clavoie/httpu"
+)
+
+// MediaType is the media type of MessagePack responses
+const MediaType = "application/msgpack"
+
+// Encoder is the httpu.Encoder that writes responses as MessagePack
+var Encoder httpu.Encoder = httpu.EncoderFunc(Encode)
+
+// WithEncoder returns an httpu.Option that registers Encoder for MediaType
+func WithEncoder() httpu.Option {
+	return httpu.WithEncoder(MediaType, Encoder)
+}
+
+// Encode writes src to w as MessagePack. Structs are written as maps of their fields, which
+// are named by their `msgpack:"name"` tag, their `json:"name"` tag, or their field name, in
+// that order. Fields tagged with "-" are skipped, fields tagged with omitempty are skipped
+// when they are empty, and the fields of embedded structs are written as if they were fields
+// of the outer struct. Maps are written with their keys sorted, time.Time is written as the
+// timestamp extension type, other values that implement encoding.TextMarshaler are written as
+// strings, and []byte is written as bin. Integers are written in their smallest format.
+func Encode(w io.Writer, src interface{}) error {
+	bw := bufio.NewWriter(w)
+	err := encode(bw, reflect.ValueOf(src))
+
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+var (
+	// timeType is the reflect.Type of time.Time
+	timeType = reflect.TypeOf(time.Time{})
+
+	// textMarshalerType is the reflect.Type of encoding.TextMarshaler
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// encode writes the MessagePack of v to w
+func encode(w *bufio.Writer, v reflect.Value) error {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return w.WriteByte(0xc0)
+		}
+
+		if v.Type().Implements(textMarshalerType) && v.Type() != reflect.PointerTo(timeType) {
+			break
+		}
+
+		v = v.Elem()
+	}
+
+	if v.IsValid() == false {
+		return w.WriteByte(0xc0)
+	}
+
+	if v.Type() == timeType {
+		return writeTime(w, v.Interface().(time.Time))
+	}
+
+	if v.Type().Implements(textMarshalerType) {
+		data, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+
+		if err != nil {
+			return err
+		}
+
+		return writeString(w, string(data))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return w.WriteByte(0xc3)
+		}
+
+		return w.WriteByte(0xc2)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return writeInt(w, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return writeUint(w, v.Uint())
+	case reflect.Float32:
+		return writeValue(w, 0xca, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		return writeValue(w, 0xcb, math.Float64bits(v.Float()))
+	case reflect.String:
+		return writeString(w, v.String())
+	case reflect.Map:
+		if v.IsNil() {
+			return w.WriteByte(0xc0)
+		}
+
+		return writeMap(w, v)
+	case reflect.Struct:
+		return writeStruct(w, v)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return w.WriteByte(0xc0)
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			return writeBin(w, data)
+		}
+
+		err := writeHeader(w, 0x90, 0xdc, 0xdd, 15, v.Len())
+
+		for index := 0; index < v.Len() && err == nil; index++ {
+			err = encode(w, v.Index(index))
+		}
+
+		return err
+	}
+
+	return fmt.Errorf("msgpack: cannot encode %v", v.Type())
+}
+
+// writeValue writes the format byte followed by value in big endian order, using the size
+// of value
+func writeValue(w *bufio.Writer, format byte, value interface{}) error {
+	err := w.WriteByte(format)
+
+	if err != nil {
+		return err
+	}
+
+	return binary.Write(w, binary.BigEndian, value)
+}
+
+// writeInt writes n in the smallest format that holds it
+func writeInt(w *bufio.Writer, n int64) error {
+	switch {
+	case n >= 0:
+		return writeUint(w, uint64(n))
+	case n >= -32:
+		return w.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		return writeValue(w, 0xd0, int8(n))
+	case n >= math.MinInt16:
+		return writeValue(w, 0xd1, int16(n))
+	case n >= math.MinInt32:
+		return writeValue(w, 0xd2, int32(n))
+	}
+
+	return writeValue(w, 0xd3, n)
+}
+
+// writeUint writes n in the smallest format that holds it
+func writeUint(w *bufio.Writer, n uint64) error {
+	switch {
+	case n <= math.MaxInt8:
+		return w.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		return writeValue(w, 0xcc, uint8(n))
+	case n <= math.MaxUint16:
+		return writeValue(w, 0xcd, uint16(n))
+	case n <= math.MaxUint32:
+		return writeValue(w, 0xce, uint32(n))
+	}
+
+	return writeValue(w, 0xcf, n)
+}
+
+// writeHeader writes the header of a string, array, or map of length n. Lengths up to
+// fixMax use the fix format, which holds the length in the low bits of fix, and longer
+// lengths use the 16 or 32 bit formats.
+func writeHeader(w *bufio.Writer, fix, format16, format32 byte, fixMax, n int) error {
+	switch {
+	case n <= fixMax:
+		return w.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		return writeValue(w, format16, uint16(n))
+	case uint64(n) <= math.MaxUint32:
+		return writeValue(w, format32, uint32(n))
+	}
+
+	return fmt.Errorf("msgpack: length %v is too long", n)
+}
+
+// writeString writes s as a str
+func writeString(w *bufio.Writer, s string) error {
+	var err error
+
+	if len(s) > 31 && len(s) <= math.MaxUint8 {
+		err = writeValue(w, 0xd9, uint8(len(s)))
+	} else {
+		err = writeHeader(w, 0xa0, 0xda, 0xdb, 31, len(s))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.WriteString(s)
+	return err
+}
+
+// writeBin writes data as a bin
+func writeBin(w *bufio.Writer, data []byte) error {
+	var err error
+
+	switch {
+	case len(data) <= math.MaxUint8:
+		err = writeValue(w, 0xc4, uint8(len(data)))
+	case len(data) <= math.MaxUint16:
+		err = writeValue(w, 0xc5, uint16(len(data)))
+	case uint64(len(data)) <= math.MaxUint32:
+		err = writeValue(w, 0xc6, uint32(len(data)))
+	default:
+		err = fmt.Errorf("msgpack: length %v is too long", len(data))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// writeTime writes t as the timestamp extension type, in the smallest of its formats that
+// holds t
+func writeTime(w *bufio.Writer, t time.Time) error {
+	seconds, nanos := t.Unix(), int64(t.Nanosecond())
+
+	switch {
+	case seconds >= 0 && seconds <= math.MaxUint32 && nanos == 0:
+		_, err := w.Write([]byte{0xd6, 0xff})
+
+		if err != nil {
+			return err
+		}
+
+		return binary.Write(w, binary.BigEndian, uint32(seconds))
+	case seconds >= 0 && seconds < 1<<34:
+		_, err := w.Write([]byte{0xd7, 0xff})
+
+		if err != nil {
+			return err
+		}
+
+		return binary.Write(w, binary.BigEndian, uint64(nanos)<<34|uint64(seconds))
+	}
+
+	_, err := w.Write([]byte{0xc7, 12, 0xff})
+
+	if err != nil {
+		return err
+	}
+
+	return binary.Write(w, binary.BigEndian, struct {
+		Nanos   uint32
+		Seconds int64
+	}{uint32(nanos), seconds})
+}
+
+// mapEntry is a key and value of a map
+type mapEntry struct {
+	key   string
+	value reflect.Value
+}
+
+// writeMap writes the map v with its keys sorted. Keys are written as strings.
+func writeMap(w *bufio.Writer, v reflect.Value) error {
+	entries := make([]mapEntry, 0, v.Len())
+	iter := v.MapRange()
+
+	for iter.Next() {
+		key := reflect.Indirect(iter.Key())
+		var name string
+
+		if key.Type().Implements(textMarshalerType) {
+			data, err := key.Interface().(encoding.TextMarshaler).MarshalText()
+
+			if err != nil {
+				return err
+			}
+
+			name = string(data)
+		} else {
+			name = fmt.Sprint(key.Interface())
+		}
+
+		entries = append(entries, mapEntry{name, iter.Value()})
+	}
+
+	sort.Slice(entries, func(a, b int) bool { return entries[a].key < entries[b].key })
+	return writeEntries(w, entries)
+}
+
+// writeStruct writes the fields of the struct v as a map
+func writeStruct(w *bufio.Writer, v reflect.Value) error {
+	return writeEntries(w, structEntries(v, nil))
+}
+
+// writeEntries writes the entries as a map
+func writeEntries(w *bufio.Writer, entries []mapEntry) error {
+	err := writeHeader(w, 0x80, 0xde, 0xdf, 15, len(entries))
+
+	for index := 0; index < len(entries) && err == nil; index++ {
+		err = writeString(w, entries[index].key)
+
+		if err == nil {
+			err = encode(w, entries[index].value)
+		}
+	}
+
+	return err
+}
+
+// structEntries appends the entries of the fields of the struct v to entries
+func structEntries(v reflect.Value, entries []mapEntry) []mapEntry {
+	structType := v.Type()
+
+	for index := 0; index < structType.NumField(); index++ {
+		field := structType.Field(index)
+		name, omitEmpty, isTagged := fieldName(field)
+
+		if name == "-" && isTagged {
+			continue
+		}
+
+		value := v.Field(index)
+
+		if field.Anonymous && isTagged == false {
+			embedded := value
+
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				entries = structEntries(embedded, entries)
+				continue
+			}
+		}
+
+		if field.IsExported() == false || (omitEmpty && value.IsZero()) {
+			continue
+		}
+
+		if (value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && omitEmpty && value.Len() == 0 {
+			continue
+		}
+
+		entries = append(entries, mapEntry{name, value})
+	}
+
+	return entries
+}
+
+// fieldName returns the name of the field from its msgpack or json tag, or its field name if
+// it has neither, and whether it is tagged omitempty
+func fieldName(field reflect.StructField) (name string, omitEmpty bool, isTagged bool) {
+	tag, hasTag := field.Tag.Lookup("msgpack")
+
+	if hasTag == false {
+		tag, hasTag = field.Tag.Lookup("json")
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+	omitEmpty = strings.Contains(","+options+",", ",omitempty,")
+
+	if tag == "-" {
+		return "-", false, true
+	}
+
+	if name == "" {
+		return field.Name, omitEmpty, false
+	}
+
+	return name, omitEmpty, hasTag
+}
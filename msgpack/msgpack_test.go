@@ -0,0 +1,84 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clavoie/httpu"
+	"github.com/clavoie/httpu/msgpack"
+	"github.com/clavoie/logu/v2"
+)
+
+func TestEncode(t *testing.T) {
+	type Base struct {
+		Id int
+	}
+
+	type Item struct {
+		Base
+		Name   string `json:"name"`
+		Secret string `msgpack:"-"`
+		Note   string `msgpack:"note,omitempty"`
+	}
+
+	tests := []struct {
+		name     string
+		src      interface{}
+		expected []byte
+	}{
+		{"Nil", nil, []byte{0xc0}},
+		{"Bool", true, []byte{0xc3}},
+		{"FixInt", 7, []byte{0x07}},
+		{"NegativeFixInt", -1, []byte{0xff}},
+		{"Uint8", 200, []byte{0xcc, 0xc8}},
+		{"Int16", -200, []byte{0xd1, 0xff, 0x38}},
+		{"Uint32", 1 << 20, []byte{0xce, 0x00, 0x10, 0x00, 0x00}},
+		{"Float64", 1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"FixStr", "hi", []byte{0xa2, 'h', 'i'}},
+		{"Str8", strings.Repeat("a", 32), append([]byte{0xd9, 32}, strings.Repeat("a", 32)...)},
+		{"Bin", []byte{1, 2}, []byte{0xc4, 2, 1, 2}},
+		{"Array", []int{1, 2}, []byte{0x92, 1, 2}},
+		{"Map", map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 1, 0xa1, 'b', 2}},
+		{"Struct", &Item{Base{1}, "x", "hidden", ""}, []byte{0x82, 0xa2, 'I', 'd', 1, 0xa4, 'n', 'a', 'm', 'e', 0xa1, 'x'}},
+		{"Timestamp32", time.Unix(1, 0), []byte{0xd6, 0xff, 0, 0, 0, 1}},
+		{"Timestamp64", time.Unix(1, 1), []byte{0xd7, 0xff, 0, 0, 0, 0x04, 0, 0, 0, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+
+			if err := msgpack.Encode(buf, test.src); err != nil {
+				t.Fatal(err)
+			}
+
+			if bytes.Equal(buf.Bytes(), test.expected) == false {
+				t.Fatalf("Unexpected msgpack: % x", buf.Bytes())
+			}
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if msgpack.Encode(new(bytes.Buffer), make(chan int)) == nil {
+			t.Fatal("Was expecting an error")
+		}
+	})
+}
+
+func TestWithEncoder(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://test.com/items", nil)
+	r.Header.Set("Accept", "application/msgpack")
+	w := httptest.NewRecorder()
+	i := httpu.NewImplWithOptions(w, r, logu.NewGoLogger(), msgpack.WithEncoder())
+
+	if i.EncodeOr500([]int{1}, "Could not encode") {
+		t.Fatal("Was not expecting an error")
+	}
+
+	if w.Header().Get("Content-Type") != msgpack.MediaType || bytes.Equal(w.Body.Bytes(), []byte{0x91, 1}) == false {
+		t.Fatal("Unexpected response", w.Header(), w.Body.Bytes())
+	}
+}
@@ -0,0 +1,285 @@
+package httpu
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+)
+
+// defaultMultipartMaxMemory is the number of bytes of a multipart form held in memory
+// when MultipartLimits.MaxMemory is not set
+const defaultMultipartMaxMemory = 10000000
+
+var (
+	// ErrFileTooLarge is returned when an uploaded file is larger than
+	// MultipartLimits.MaxFileBytes.
+	ErrFileTooLarge = errors.New("httpu: uploaded file too large")
+
+	// ErrTooManyParts is returned when a multipart form has more parts than
+	// MultipartLimits.MaxParts.
+	ErrTooManyParts = errors.New("httpu: too many parts in multipart form")
+)
+
+// MultipartLimits are the limits applied when parsing a multipart form from a request. A
+// limit of 0 or less is not enforced, except for MaxMemory which falls back to its default.
+type MultipartLimits struct {
+	// MaxMemory is the number of bytes of the form held in memory. The remainder of the
+	// files in the form are stored in temporary files on disk. The default is 10000000.
+	MaxMemory int64
+
+	// MaxRequestBytes is the maximum size of the entire request body
+	MaxRequestBytes int64
+
+	// MaxFileBytes is the maximum size of any one uploaded file
+	MaxFileBytes int64
+
+	// MaxParts is the maximum number of parts, files and values, in the form
+	MaxParts int
+}
+
+// parseMultipartForm parses the multipart form of the request within the multipart limits.
+// The limits are enforced as the body is read, so a request that exceeds them is rejected
+// without the rest of it being read or stored. If there is an error the status code to write
+// for it is returned: a HTTP 413 if a limit is exceeded, a HTTP 500 if the form could not be
+// stored, and a HTTP 400 if the form itself is invalid. If no error is returned the caller
+// must remove the form with removeMultipartForm.
+func (i *impl) parseMultipartForm() (int, error) {
+	limits := i.o.multipartLimits
+	body, statusCode, err := i.requestBody(limits.MaxRequestBytes)
+
+	if err != nil {
+		return statusCode, err
+	}
+
+	maxMemory := limits.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMultipartMaxMemory
+	}
+
+	_, params, _ := mime.ParseMediaType(i.r.Header.Get("Content-Type"))
+	limitBody := newMultipartLimitReader(body, params["boundary"], limits)
+	defer limitBody.Close()
+
+	i.r.Body = limitBody
+	err = i.r.ParseMultipartForm(maxMemory)
+
+	if err == nil {
+		err = limitBody.limitErr()
+
+		if err != nil {
+			i.removeMultipartForm()
+		}
+	}
+
+	if err != nil {
+		return multipartErrStatusCode(err), err
+	}
+
+	return 0, nil
+}
+
+// multipartLimitReader is the request body of a multipart form that enforces the
+// MaxFileBytes and MaxParts limits while the form is read. Everything read from the body is
+// copied to a second multipart.Reader, which counts the parts of the form and the size of
+// each file. As soon as a limit is exceeded the next read from the body fails with
+// ErrFileTooLarge or ErrTooManyParts.
+type multipartLimitReader struct {
+	io.ReadCloser
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+// newMultipartLimitReader returns body with the limits of the multipart form, delimited by
+// boundary, enforced. If there are no limits to enforce, or no boundary, the limits are not
+// checked and the form is left for ParseMultipartForm to reject.
+func newMultipartLimitReader(body io.ReadCloser, boundary string, limits MultipartLimits) *multipartLimitReader {
+	mlr := &multipartLimitReader{ReadCloser: body, done: make(chan struct{})}
+
+	if boundary == "" || (limits.MaxFileBytes <= 0 && limits.MaxParts <= 0) {
+		close(mlr.done)
+		return mlr
+	}
+
+	pr, pw := io.Pipe()
+	mlr.pw = pw
+
+	go func() {
+		defer close(mlr.done)
+
+		mlr.err = checkMultipartLimits(multipart.NewReader(pr, boundary), limits)
+		pr.CloseWithError(mlr.err)
+	}()
+
+	return mlr
+}
+
+func (mlr *multipartLimitReader) Read(p []byte) (int, error) {
+	n, err := mlr.ReadCloser.Read(p)
+
+	if mlr.pw == nil {
+		return n, err
+	}
+
+	if n > 0 {
+		_, writeErr := mlr.pw.Write(p[:n])
+
+		if writeErr != nil {
+			mlr.wait()
+
+			if mlr.err != nil {
+				return 0, mlr.err
+			}
+		}
+	}
+
+	if err != nil {
+		mlr.wait()
+
+		if mlr.err != nil {
+			return n, mlr.err
+		}
+	}
+
+	return n, err
+}
+
+func (mlr *multipartLimitReader) Close() error {
+	mlr.wait()
+	return mlr.ReadCloser.Close()
+}
+
+// limitErr stops checking the limits of the form, returning ErrFileTooLarge or
+// ErrTooManyParts if a limit was exceeded by what has been read so far
+func (mlr *multipartLimitReader) limitErr() error {
+	mlr.wait()
+	return mlr.err
+}
+
+// wait stops copying the body to the checking multipart.Reader, and waits for it to finish
+func (mlr *multipartLimitReader) wait() {
+	if mlr.pw != nil {
+		mlr.pw.Close()
+		mlr.pw = nil
+	}
+
+	<-mlr.done
+}
+
+// checkMultipartLimits reads each part of the form, returning ErrTooManyParts or
+// ErrFileTooLarge as soon as a limit is exceeded. Any other error reading the form is left
+// for ParseMultipartForm to report, and nil is returned.
+func checkMultipartLimits(reader *multipart.Reader, limits MultipartLimits) error {
+	for parts := 1; ; parts++ {
+		part, err := reader.NextPart()
+
+		if err != nil {
+			return nil
+		}
+
+		if limits.MaxParts > 0 && parts > limits.MaxParts {
+			return ErrTooManyParts
+		}
+
+		var content io.Reader = part
+		if part.FileName() != "" {
+			content = newLimitReader(part, limits.MaxFileBytes, ErrFileTooLarge)
+		}
+
+		_, err = io.Copy(io.Discard, content)
+
+		if errors.Is(err, ErrFileTooLarge) {
+			return err
+		}
+
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// removeMultipartForm removes the temporary files of the parsed multipart form, logging
+// any error encountered
+func (i *impl) removeMultipartForm() {
+	err := i.r.MultipartForm.RemoveAll()
+
+	if err != nil {
+		i.l.Errorf("%v", err)
+	}
+}
+
+// multipartErrStatusCode returns the status code to write for an error encountered while
+// reading a multipart form
+func multipartErrStatusCode(err error) int {
+	var pathErr *os.PathError
+	var syscallErr *os.SyscallError
+
+	switch {
+	case errors.Is(err, ErrBodyTooLarge), errors.Is(err, ErrFileTooLarge), errors.Is(err, ErrTooManyParts),
+		errors.Is(err, multipart.ErrMessageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUploadTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	case errors.As(err, &pathErr), errors.As(err, &syscallErr):
+		return http.StatusInternalServerError
+	}
+
+	return http.StatusBadRequest
+}
+
+// streamJsonFile works like TryDecodeJsonFile, reading the multipart form directly from the
+// request body instead of parsing it into memory and temporary files first. Parts before the
+// file are skipped, and parts after it are never read.
+func (i *impl) streamJsonFile(filename string, dst interface{}) bool {
+	limits := i.o.multipartLimits
+	body, statusCode, err := i.requestBody(limits.MaxRequestBytes)
+
+	if i.WriteIfErr(err, statusCode, "Could not parse multipart form for file %v", filename) {
+		return true
+	}
+
+	defer body.Close()
+	i.r.Body = body
+	reader, err := i.r.MultipartReader()
+
+	if i.WriteIfErr(err, multipartErrStatusCode(err), "Could not parse multipart form for file %v", filename) {
+		return true
+	}
+
+	for parts := 1; ; parts++ {
+		part, err := reader.NextPart()
+
+		if err == io.EOF {
+			return i.Write400IfErr(http.ErrMissingFile, "Could not find file with name %v in upload", filename)
+		}
+
+		if err == nil && limits.MaxParts > 0 && parts > limits.MaxParts {
+			err = ErrTooManyParts
+		}
+
+		if i.WriteIfErr(err, multipartErrStatusCode(err), "Could not parse multipart form for file %v", filename) {
+			return true
+		}
+
+		if part.FormName() != filename || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		defer part.Close()
+		file, err := i.o.uploadPolicy.checkUpload(part.FileName(), part.Header, newLimitReader(part, limits.MaxFileBytes, ErrFileTooLarge))
+
+		if i.WriteIfErr(err, multipartErrStatusCode(err), "Upload not allowed for file %v", filename) {
+			return true
+		}
+
+		decoder := json.NewDecoder(file)
+		err = decoder.Decode(dst)
+
+		return i.WriteIfErr(err, multipartErrStatusCode(err), "Could not decode json from file %v", filename)
+	}
+}
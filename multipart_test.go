@@ -0,0 +1,265 @@
+package httpu_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clavoie/httpu"
+	mock_v2 "github.com/clavoie/logu/v2/mock_logu"
+	"github.com/golang/mock/gomock"
+)
+
+// multipartFile is a file written to a test multipart form
+type multipartFile struct {
+	field       string
+	filename    string
+	contentType string
+	content     string
+}
+
+// newMultipartImpl returns an Impl for a POST request carrying a multipart form with the
+// given files and values
+func newMultipartImpl(t *testing.T, files []multipartFile, values map[string]string, opts ...httpu.Option) (*http.Request, *httptest.ResponseRecorder, *mock_v2.MockLogger, httpu.Impl, func()) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	for name, value := range values {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, file := range files {
+		header := make(map[string][]string)
+		header["Content-Disposition"] = []string{`form-data; name="` + file.field + `"; filename="` + file.filename + `"`}
+
+		if file.contentType != "" {
+			header["Content-Type"] = []string{file.contentType}
+		}
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		part.Write([]byte(file.content))
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "http://test.com/upload", body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	ctrl := gomock.NewController(t)
+	l := mock_v2.NewMockLogger(ctrl)
+
+	return r, w, l, httpu.NewImplWithOptions(w, r, l, opts...), ctrl.Finish
+}
+
+func TestMultipartLimits(t *testing.T) {
+	type Json struct {
+		Field int
+	}
+
+	file := multipartFile{field: "data", filename: "data.json", content: `{"Field":100}`}
+	values := map[string]string{"a": "1", "b": "2"}
+
+	t.Run("WithinLimits", func(t *testing.T) {
+		limits := httpu.MultipartLimits{MaxMemory: 1, MaxRequestBytes: 4096, MaxFileBytes: 13, MaxParts: 3}
+		_, w, _, i, finish := newMultipartImpl(t, []multipartFile{file}, values, httpu.WithMultipartLimits(limits))
+		defer finish()
+
+		j := new(Json)
+		if i.TryDecodeJsonFile("data", j) {
+			t.Fatal("Was not expecting an error", w.Code)
+		}
+
+		if j.Field != 100 {
+			t.Fatal("Unexpected decode", j.Field)
+		}
+	})
+
+	tests := []struct {
+		name          string
+		limits        httpu.MultipartLimits
+		contentLength int64
+		err           error
+	}{
+		{"ContentLength", httpu.MultipartLimits{MaxRequestBytes: 64}, 0, httpu.ErrBodyTooLarge},
+		{"RequestBytes", httpu.MultipartLimits{MaxRequestBytes: 64}, -1, nil},
+		{"FileBytes", httpu.MultipartLimits{MaxFileBytes: 12}, 0, httpu.ErrFileTooLarge},
+		{"Parts", httpu.MultipartLimits{MaxParts: 2}, 0, httpu.ErrTooManyParts},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, w, l, i, finish := newMultipartImpl(t, []multipartFile{file}, values, httpu.WithMultipartLimits(test.limits))
+			defer finish()
+
+			if test.contentLength != 0 {
+				r.ContentLength = test.contentLength
+			}
+
+			if test.err == nil {
+				l.EXPECT().Warningf(NonEmptyStr(), "data", NonEmptyStr())
+			} else {
+				l.EXPECT().Warningf(NonEmptyStr(), "data", test.err)
+			}
+
+			if i.TryDecodeJsonFile("data", new(Json)) == false {
+				t.Fatal("Was expecting an error")
+			}
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Fatal("Unexpected code", w.Code)
+			}
+		})
+	}
+
+	t.Run("StopsReading", func(t *testing.T) {
+		large := multipartFile{field: "data", filename: "data.json", content: strings.Repeat(" ", 5<<20)}
+		limits := httpu.MultipartLimits{MaxFileBytes: 100}
+		r, w, l, i, finish := newMultipartImpl(t, []multipartFile{large}, nil, httpu.WithMultipartLimits(limits))
+		defer finish()
+
+		body := &countingReader{r: r.Body}
+		r.Body = io.NopCloser(body)
+		l.EXPECT().Warningf(NonEmptyStr(), "data", httpu.ErrFileTooLarge)
+
+		if i.TryDecodeJsonFile("data", new(Json)) == false || w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatal("Was expecting an error", w.Code)
+		}
+
+		if body.n > 1<<20 {
+			t.Fatal("Read too much of the body", body.n)
+		}
+	})
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+func TestMultipartStreaming(t *testing.T) {
+	type Json struct {
+		Field int
+	}
+
+	file := multipartFile{field: "data", filename: "data.json", content: `{"Field":100}`}
+	values := map[string]string{"a": "1", "b": "2"}
+
+	t.Run("Success", func(t *testing.T) {
+		other := multipartFile{field: "other", filename: "other.json", content: `{"Field":1}`}
+		_, w, _, i, finish := newMultipartImpl(t, []multipartFile{other, file}, values, httpu.WithMultipartStreaming(true))
+		defer finish()
+
+		j := new(Json)
+		if i.TryDecodeJsonFile("data", j) {
+			t.Fatal("Was not expecting an error", w.Code)
+		}
+
+		if j.Field != 100 {
+			t.Fatal("Unexpected decode", j.Field)
+		}
+	})
+
+	t.Run("FieldNotFile", func(t *testing.T) {
+		_, w, l, i, finish := newMultipartImpl(t, nil, map[string]string{"data": `{"Field":100}`}, httpu.WithMultipartStreaming(true))
+		defer finish()
+
+		l.EXPECT().Warningf(NonEmptyStr(), "data", http.ErrMissingFile)
+
+		if i.TryDecodeJsonFile("data", new(Json)) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatal("Unexpected code", w.Code)
+		}
+	})
+
+	t.Run("NotMultipart", func(t *testing.T) {
+		r, w, l, i, finish := newMultipartImpl(t, []multipartFile{file}, values, httpu.WithMultipartStreaming(true))
+		defer finish()
+
+		r.Header.Set("Content-Type", "application/json")
+		l.EXPECT().Warningf(NonEmptyStr(), "data", NonEmptyStr())
+
+		if i.TryDecodeJsonFile("data", new(Json)) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatal("Unexpected code", w.Code)
+		}
+	})
+
+	t.Run("InvalidJson", func(t *testing.T) {
+		invalid := multipartFile{field: "data", filename: "data.json", content: `{"Field":`}
+		_, w, l, i, finish := newMultipartImpl(t, []multipartFile{invalid}, values, httpu.WithMultipartStreaming(true))
+		defer finish()
+
+		l.EXPECT().Warningf(NonEmptyStr(), "data", NonEmptyStr())
+
+		if i.TryDecodeJsonFile("data", new(Json)) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatal("Unexpected code", w.Code)
+		}
+	})
+
+	tests := []struct {
+		name          string
+		limits        httpu.MultipartLimits
+		contentLength int64
+		err           error
+	}{
+		{"ContentLength", httpu.MultipartLimits{MaxRequestBytes: 64}, 0, httpu.ErrBodyTooLarge},
+		{"RequestBytes", httpu.MultipartLimits{MaxRequestBytes: 64}, -1, nil},
+		{"FileBytes", httpu.MultipartLimits{MaxFileBytes: 12}, 0, httpu.ErrFileTooLarge},
+		{"Parts", httpu.MultipartLimits{MaxParts: 2}, 0, httpu.ErrTooManyParts},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := []httpu.Option{httpu.WithMultipartLimits(test.limits), httpu.WithMultipartStreaming(true)}
+			r, w, l, i, finish := newMultipartImpl(t, []multipartFile{file}, values, opts...)
+			defer finish()
+
+			if test.contentLength != 0 {
+				r.ContentLength = test.contentLength
+			}
+
+			if test.err == nil {
+				l.EXPECT().Warningf(NonEmptyStr(), "data", NonEmptyStr())
+			} else {
+				l.EXPECT().Warningf(NonEmptyStr(), "data", test.err)
+			}
+
+			if i.TryDecodeJsonFile("data", new(Json)) == false {
+				t.Fatal("Was expecting an error")
+			}
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Fatal("Unexpected code", w.Code)
+			}
+		})
+	}
+}
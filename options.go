@@ -0,0 +1,437 @@
+package httpu
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Option configures the behavior of an Impl. Options can be supplied when the Impl is
+// created with NewImplWithOptions or NewDiDefs, or for a single call with Impl.With.
+type Option func(*options)
+
+// options holds the configurable settings of an impl, grouped by the feature they configure
+type options struct {
+	decoders            decoders
+	decompressors       decompressors
+	maxBodyBytes        int64
+	maxItems            int
+	strictJson          bool
+	multipartLimits     MultipartLimits
+	multipartStreaming  bool
+	uploadPolicy        *UploadPolicy
+	encoders            encoders
+	headers             http.Header
+	responseBufferSize  int
+	statusCode          int
+	compression         bool
+	compressionMinSize  int
+	compressors         compressors
+	etag                string
+	etagMode            ETagMode
+	lastModified        time.Time
+	heartbeatInterval   time.Duration
+	streamFlushInterval time.Duration
+	errMap              *ErrMap
+	problemDetails      bool
+}
+
+// defaultOpts are the options set with SetDefaultOptions
+var defaultOpts struct {
+	sync.RWMutex
+	opts []Option
+}
+
+// SetDefaultOptions sets the options applied to every Impl created from this point on,
+// including those created by the top level package functions. Options passed to
+// NewImplWithOptions, NewDiDefs, or Impl.With are applied on top of the defaults. Each call
+// replaces the defaults set by the previous call.
+func SetDefaultOptions(opts ...Option) {
+	defaultOpts.Lock()
+	defer defaultOpts.Unlock()
+
+	defaultOpts.opts = append([]Option(nil), opts...)
+}
+
+// newOptions returns the default options with each Option applied in order
+func newOptions(opts ...Option) *options {
+	o := &options{
+		decoders:            defaultDecoders(),
+		decompressors:       defaultDecompressors(),
+		encoders:            defaultEncoders(),
+		responseBufferSize:  defaultResponseBufferSize,
+		compressionMinSize:  defaultCompressionMinSize,
+		compressors:         defaultCompressors(),
+		heartbeatInterval:   defaultHeartbeatInterval,
+		streamFlushInterval: defaultStreamFlushInterval,
+	}
+
+	defaultOpts.RLock()
+	o.apply(defaultOpts.opts...)
+	defaultOpts.RUnlock()
+
+	o.apply(opts...)
+
+	return o
+}
+
+// apply runs each Option against the options
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithCompression turns compression of the responses written by the encode functions on or
+// off. When on, responses at least as large as the size set by WithCompressionMinSize are
+// compressed with the content coding the Accept-Encoding header of the request prefers, the
+// same way Compress compresses them. Compression is off by default.
+func WithCompression(compress bool) Option {
+	return func(o *options) {
+		o.compression = compress
+	}
+}
+
+// WithCompressionMinSize sets the size in bytes below which responses are not compressed, as
+// compressing them costs more than it saves. The default is 1024 bytes. A size of 0 or less
+// compresses every response.
+func WithCompressionMinSize(n int) Option {
+	return func(o *options) {
+		o.compressionMinSize = n
+	}
+}
+
+// WithCompressor registers the Compressor used for the given content coding, replacing any
+// Compressor already registered for it. Passing a nil Compressor removes the coding.
+//
+// Compressors for gzip and deflate are registered by default, in that order. A new coding is
+// added to the front, so that when the Accept-Encoding header of a request prefers several
+// codings equally the most recently added is used. Other codings, such as br or zstd, can be
+// registered with the library of your choice:
+//
+//	httpu.NewDiDefs(httpu.WithCompression(true), httpu.WithCompressor("br", newBrotliWriter))
+func WithCompressor(coding string, c Compressor) Option {
+	coding = strings.ToLower(coding)
+
+	return func(o *options) {
+		updated := make(compressors, 0, len(o.compressors)+1)
+		replaced := false
+
+		for _, entry := range o.compressors {
+			if entry.coding != coding {
+				updated = append(updated, entry)
+			} else if c != nil {
+				updated = append(updated, compressorEntry{coding, c})
+				replaced = true
+			}
+		}
+
+		if c != nil && replaced == false {
+			updated = append(compressors{{coding, c}}, updated...)
+		}
+
+		o.compressors = updated
+	}
+}
+
+// WithDecoder registers the Decoder used by DecodeOr400 for request bodies with the given
+// media type, replacing any Decoder already registered for it. Passing a nil Decoder removes
+// the media type from the registry.
+//
+// Decoders for application/json, application/xml, text/xml, and
+// application/x-www-form-urlencoded are registered by default. Other formats, such as
+// application/msgpack or application/cbor, can be registered with the library of your choice:
+//
+//	httpu.NewDiDefs(httpu.WithDecoder("application/msgpack", httpu.DecoderFunc(decodeMsgpack)))
+func WithDecoder(mediaType string, d Decoder) Option {
+	mediaType = strings.ToLower(mediaType)
+
+	return func(o *options) {
+		updated := make(decoders, len(o.decoders)+1)
+
+		for existingType, existing := range o.decoders {
+			updated[existingType] = existing
+		}
+
+		if d == nil {
+			delete(updated, mediaType)
+		} else {
+			updated[mediaType] = d
+		}
+
+		o.decoders = updated
+	}
+}
+
+// WithDecompressor registers the Decompressor used for request bodies with the given
+// Content-Encoding, replacing any Decompressor already registered for it. Passing a nil
+// Decompressor removes the coding. Request bodies with a coding that has no Decompressor are
+// rejected with a HTTP 415.
+//
+// Decompressors for gzip and deflate are registered by default. Other codings, such as br or
+// zstd, can be registered with the library of your choice:
+//
+//	httpu.NewDiDefs(httpu.WithDecompressor("zstd", newZstdReader))
+func WithDecompressor(coding string, d Decompressor) Option {
+	coding = strings.ToLower(coding)
+
+	return func(o *options) {
+		updated := make(decompressors, len(o.decompressors)+1)
+
+		for existingCoding, existing := range o.decompressors {
+			updated[existingCoding] = existing
+		}
+
+		if d == nil {
+			delete(updated, coding)
+		} else {
+			updated[coding] = d
+		}
+
+		o.decompressors = updated
+	}
+}
+
+// WithEncoder registers the Encoder used by EncodeOr500 when the request accepts the given
+// media type. If an Encoder is already registered for the media type it is replaced, keeping
+// its place in the order, otherwise the Encoder is added to the end. When the Accept header
+// of a request prefers several media types equally the earliest registered Encoder is used.
+// Passing a nil Encoder removes the media type from the registry.
+//
+// Encoders for application/json, application/xml, and text/csv are registered by default,
+// in that order. Encoders for application/yaml and application/msgpack are provided by the
+// yaml and msgpack subpackages, which only need to be imported if they are used:
+//
+//	httpu.NewDiDefs(yaml.WithEncoder(), msgpack.WithEncoder())
+//
+// Any other format can be registered with the library of your choice.
+func WithEncoder(mediaType string, e Encoder) Option {
+	mediaType = strings.ToLower(mediaType)
+
+	return func(o *options) {
+		updated := make(encoders, 0, len(o.encoders)+1)
+		replaced := false
+
+		for _, entry := range o.encoders {
+			if entry.mediaType != mediaType {
+				updated = append(updated, entry)
+			} else if e != nil {
+				updated = append(updated, encoderEntry{mediaType, e})
+				replaced = true
+			}
+		}
+
+		if e != nil && replaced == false {
+			updated = append(updated, encoderEntry{mediaType, e})
+		}
+
+		o.encoders = updated
+	}
+}
+
+// WithErrMap sets the ErrMap consulted by WriteErr to find the status code and public
+// message of an error. Registering it with NewDiDefs shares one set of mappings between
+// every handler:
+//
+//	httpu.NewDiDefs(httpu.WithErrMap(errMap))
+func WithErrMap(m *ErrMap) Option {
+	return func(o *options) {
+		o.errMap = m
+	}
+}
+
+// WithETag sets whether the encode functions generate an ETag from the encoded response, and
+// whether it is strong or weak. When a GET or HEAD request has an If-None-Match header that
+// matches the ETag a HTTP 304 is written without a body. ETags can only be generated for
+// responses that fit in the buffer set by WithResponseBufferSize, and larger responses are
+// written without one. ETags are not generated by default.
+func WithETag(mode ETagMode) Option {
+	return func(o *options) {
+		o.etagMode = mode
+	}
+}
+
+// WithETagValue sets the ETag of the response written by the encode functions, such as a
+// version number of the resource, instead of generating one. It is quoted if it is not
+// already a quoted entity tag. When a GET or HEAD request has an If-None-Match header that
+// matches the ETag a HTTP 304 is written without encoding the response at all. Since the
+// value belongs to a single response it is usually set for one call with Impl.With:
+//
+//	i.With(httpu.WithETagValue(strconv.Itoa(widget.Version))).EncodeJsonOr500(widget, "Could not encode")
+//
+// An empty value clears the ETag.
+func WithETagValue(etag string) Option {
+	if etag != "" {
+		etag = quoteETag(etag)
+	}
+
+	return func(o *options) {
+		o.etag = etag
+	}
+}
+
+// WithLastModified sets the Last-Modified time of the response written by the encode
+// functions. When a GET or HEAD request has an If-Modified-Since header at or after the
+// time, and no If-None-Match header, a HTTP 304 is written without encoding the response at
+// all. Like WithETagValue it is usually set for one call with Impl.With. The zero time
+// clears the Last-Modified time.
+func WithLastModified(modtime time.Time) Option {
+	return func(o *options) {
+		o.lastModified = modtime
+	}
+}
+
+// WithHeader sets a header of the response written by the encode functions, replacing any
+// value already set for it by a previous WithHeader. The header is only added once the
+// response is encoded successfully, so it is not sent along with a HTTP 500. Since the header
+// usually belongs to a single response it is set for one call with Impl.With:
+//
+//	i.With(httpu.WithHeader("X-Total-Count", strconv.Itoa(total))).EncodeJsonOr500(page, "Could not encode")
+//
+// An empty value removes the header from those set by WithHeader.
+func WithHeader(key, value string) Option {
+	key = http.CanonicalHeaderKey(key)
+
+	return func(o *options) {
+		updated := o.headers.Clone()
+		if updated == nil {
+			updated = make(http.Header)
+		}
+
+		if value == "" {
+			delete(updated, key)
+		} else {
+			updated[key] = []string{value}
+		}
+
+		o.headers = updated
+	}
+}
+
+// WithHeartbeatInterval sets how often the event streams created by EventStreamOr500 send a
+// heartbeat comment, which keeps idle connections from being closed by proxies. The default
+// is 15 seconds, and an interval of 0 or less sends no heartbeats.
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.heartbeatInterval = d
+	}
+}
+
+// WithMaxBodyBytes limits the size of request bodies read by the decoding functions. Requests
+// whose Content-Length exceeds the limit are rejected before any of the body is read, and
+// bodies that grow past the limit while being read are cut off. In both cases a HTTP 413 is
+// written to the response. The limit applies to the decompressed size of compressed bodies,
+// which stops small bodies that decompress to enormous ones, and only the Content-Length of
+// uncompressed bodies is checked up front.
+//
+// A limit of 0 or less means request bodies are not limited, which is the default.
+func WithMaxBodyBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBodyBytes = n
+	}
+}
+
+// WithMaxItems limits the number of items DecodeEachOr400 decodes from a request body. A body
+// with more items is rejected with a HTTP 413 once the item past the limit is reached, after
+// the items before it have been handled. A limit of 0 or less means the number of items is
+// not limited, which is the default.
+func WithMaxItems(n int) Option {
+	return func(o *options) {
+		o.maxItems = n
+	}
+}
+
+// WithMultipartLimits sets the limits applied when parsing multipart forms, such as those
+// read by TryDecodeJsonFile. Requests that exceed a limit are rejected with a HTTP 413. As
+// with WithMaxBodyBytes, MaxRequestBytes applies to the decompressed size of compressed
+// requests.
+func WithMultipartLimits(limits MultipartLimits) Option {
+	return func(o *options) {
+		o.multipartLimits = limits
+	}
+}
+
+// WithMultipartStreaming turns streaming of multipart forms on or off. When on,
+// TryDecodeJsonFile reads the form directly from the request body with
+// http.Request.MultipartReader, skipping the parts before the file and decoding the file as
+// it arrives. Nothing is buffered in memory or written to temporary files on disk, which
+// suits large uploads on hosts with little disk. The MaxMemory limit does not apply when
+// streaming, but the other multipart limits do.
+func WithMultipartStreaming(streaming bool) Option {
+	return func(o *options) {
+		o.multipartStreaming = streaming
+	}
+}
+
+// WithUploadPolicy restricts the files accepted by TryDecodeJsonFile and ForEachUpload to
+// those allowed by the policy. Files that are not allowed are rejected with a HTTP 415. A nil
+// policy, the default, accepts every file.
+func WithUploadPolicy(policy *UploadPolicy) Option {
+	if policy != nil {
+		clone := *policy
+		clone.ContentTypes = append([]string(nil), policy.ContentTypes...)
+		clone.Extensions = append([]string(nil), policy.Extensions...)
+		clone.SniffedTypes = append([]string(nil), policy.SniffedTypes...)
+		clone.Sniffers = append([]Sniffer(nil), policy.Sniffers...)
+		policy = &clone
+	}
+
+	return func(o *options) {
+		o.uploadPolicy = policy
+	}
+}
+
+// WithProblemDetails turns problem details on or off. When on, the error responses written
+// by this package carry an application/problem+json body, as described in RFC 9457, with
+// the status code, its status text as the title, and the request path as the instance. Only
+// details intended for the client are written, such as the offending field of a strict json
+// decode. The message and error passed to the write functions are only ever logged.
+func WithProblemDetails(enabled bool) Option {
+	return func(o *options) {
+		o.problemDetails = enabled
+	}
+}
+
+// WithResponseBufferSize sets the number of bytes the encode functions buffer before writing
+// a response. Buffering lets a failed encode be answered with a clean HTTP 500, and lets a
+// successful one be written with an accurate Content-Length. Responses that grow past the
+// buffer size are streamed instead. The default size is 1MiB, and a size of 0 or less turns
+// buffering off.
+func WithResponseBufferSize(n int) Option {
+	return func(o *options) {
+		o.responseBufferSize = n
+	}
+}
+
+// WithStatusCode sets the status code of the response written by the encode functions in
+// place of the implicit HTTP 200, such as http.StatusCreated. If the encoding fails a HTTP
+// 500 is written instead. The conditional request handling of WithETag, WithETagValue, and
+// WithLastModified only applies to HTTP 200 responses. A status code of 0 restores the
+// default.
+func WithStatusCode(statusCode int) Option {
+	return func(o *options) {
+		o.statusCode = statusCode
+	}
+}
+
+// WithStreamFlushInterval sets the longest the streaming functions, such as
+// StreamNdjsonOr500, hold written items before flushing them to the client. Items are
+// buffered in between to avoid a write to the connection for every item, and are flushed once
+// the interval passes even if the producer of the next item is blocked. The default is 100ms,
+// and an interval of 0 or less flushes every item.
+func WithStreamFlushInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.streamFlushInterval = d
+	}
+}
+
+// WithStrictJson turns strict json decoding on or off. When strict decoding is on, request
+// bodies are rejected with a HTTP 400 if they contain fields that are not part of the
+// destination object, objects with duplicate keys, or any data after the first json value.
+// The offending field or byte offset is written to the response and to the log.
+func WithStrictJson(strict bool) Option {
+	return func(o *options) {
+		o.strictJson = strict
+	}
+}
@@ -0,0 +1,91 @@
+package httpu
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is a problem details document, as described in RFC 9457, which is written to the
+// response as application/problem+json. Everything in a Problem is sent to the client, so it
+// must never contain internal error information. The message and error passed to the write
+// functions are only ever written to the log.
+type Problem struct {
+	// Type is a URI reference that identifies the problem type. When empty the type is
+	// treated as "about:blank" by clients.
+	Type string
+
+	// Title is a short summary of the problem type. If empty the status text of Status is
+	// used.
+	Title string
+
+	// Status is the HTTP status code of the response
+	Status int
+
+	// Detail is an explanation specific to this occurrence of the problem
+	Detail string
+
+	// Instance is a URI reference that identifies this occurrence of the problem. If empty
+	// the path of the request is used.
+	Instance string
+
+	// Extensions are additional members written alongside the standard ones. Extensions
+	// cannot replace the standard members.
+	Extensions map[string]interface{}
+}
+
+// MarshalJSON writes the standard members of the Problem, omitting those that are empty,
+// followed by its Extensions.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+
+	setMember := func(name string, value interface{}, isEmpty bool) {
+		delete(members, name)
+
+		if isEmpty == false {
+			members[name] = value
+		}
+	}
+
+	setMember("type", p.Type, p.Type == "")
+	setMember("title", p.Title, p.Title == "")
+	setMember("status", p.Status, p.Status == 0)
+	setMember("detail", p.Detail, p.Detail == "")
+	setMember("instance", p.Instance, p.Instance == "")
+
+	return json.Marshal(members)
+}
+
+// complete returns a copy of the Problem with its Status set to statusCode, and with its
+// Title and Instance defaulted if they are empty. p may be nil.
+func (p *Problem) complete(statusCode int, r *http.Request) *Problem {
+	completed := new(Problem)
+
+	if p != nil {
+		*completed = *p
+	}
+
+	completed.Status = statusCode
+
+	if completed.Title == "" {
+		completed.Title = http.StatusText(statusCode)
+	}
+
+	if completed.Instance == "" && r != nil && r.URL != nil && r.URL.Path != "" {
+		completed.Instance = r.URL.Path
+	}
+
+	return completed
+}
+
+// detail returns the Detail of the Problem, or an empty string if p is nil
+func (p *Problem) detail() string {
+	if p == nil {
+		return ""
+	}
+
+	return p.Detail
+}
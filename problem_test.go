@@ -0,0 +1,144 @@
+package httpu_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clavoie/httpu"
+)
+
+func TestProblem(t *testing.T) {
+	format := "problem: %v"
+	errFormat := format + ": %v"
+	err := errors.New("internal detail")
+
+	decodeProblem := func(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+			t.Fatal("Unexpected content type", contentType)
+		}
+
+		if strings.Contains(w.Body.String(), err.Error()) {
+			t.Fatal("Internal error leaked to the response", w.Body.String())
+		}
+
+		problem := make(map[string]interface{})
+		if decodeErr := json.Unmarshal(w.Body.Bytes(), &problem); decodeErr != nil {
+			t.Fatal(decodeErr)
+		}
+
+		return problem
+	}
+
+	t.Run("MarshalJSON", func(t *testing.T) {
+		p := &httpu.Problem{
+			Type:       "https://example.com/probs/out-of-credit",
+			Status:     http.StatusForbidden,
+			Extensions: map[string]interface{}{"balance": 30, "status": "ignored", "detail": "ignored"},
+		}
+
+		data, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `{"balance":30,"status":403,"type":"https://example.com/probs/out-of-credit"}`
+		if string(data) != expected {
+			t.Fatal("Unexpected json", string(data))
+		}
+	})
+	t.Run("WriteIfErr", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, ``, httpu.WithProblemDetails(true))
+		defer finish()
+
+		l.EXPECT().Warningf(errFormat, 1, err)
+
+		if i.Write400IfErr(err, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		problem := decodeProblem(t, w)
+		if w.Code != http.StatusBadRequest || problem["status"] != 400.0 || problem["title"] != "Bad Request" || problem["instance"] != "/test" {
+			t.Fatal("Unexpected problem", w.Code, problem)
+		}
+	})
+	t.Run("WriteIfErrStrictJson", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, `{"Field":1,"Field":2}`, httpu.WithProblemDetails(true))
+		defer finish()
+
+		l.EXPECT().Warningf(errFormat, 1, NonEmptyStr())
+
+		dst := &struct{ Field int }{}
+		if i.DecodeJsonStrictOr400(dst, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		problem := decodeProblem(t, w)
+		if strings.Contains(problem["detail"].(string), `duplicate key "Field"`) == false {
+			t.Fatal("Unexpected problem", problem)
+		}
+	})
+	t.Run("WriteProblemIfErr", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, ``)
+		defer finish()
+
+		l.EXPECT().Warningf(errFormat, 1, err)
+		p := &httpu.Problem{
+			Status:     http.StatusConflict,
+			Detail:     "The widget already exists",
+			Instance:   "/widgets/1",
+			Extensions: map[string]interface{}{"widget": 1},
+		}
+
+		if i.WriteProblemIfErr(nil, p, format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if i.WriteProblemIfErr(err, p, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		problem := decodeProblem(t, w)
+		if w.Code != http.StatusConflict || problem["detail"] != p.Detail || problem["instance"] != p.Instance || problem["widget"] != 1.0 || problem["title"] != "Conflict" {
+			t.Fatal("Unexpected problem", w.Code, problem)
+		}
+	})
+	t.Run("WriteProblemIfErrDefaultStatus", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, ``)
+		defer finish()
+
+		l.EXPECT().Errorf(errFormat, 1, err)
+
+		if i.WriteProblemIfErr(err, nil, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if problem := decodeProblem(t, w); w.Code != http.StatusInternalServerError || problem["status"] != 500.0 {
+			t.Fatal("Unexpected problem", w.Code, problem)
+		}
+	})
+	t.Run("SetDefaultOptions", func(t *testing.T) {
+		httpu.SetDefaultOptions(httpu.WithProblemDetails(true))
+		defer httpu.SetDefaultOptions()
+
+		w := httptest.NewRecorder()
+		if httpu.Write500IfErr(err, w, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if problem := decodeProblem(t, w); problem["status"] != 500.0 {
+			t.Fatal("Unexpected problem", problem)
+		}
+
+		w = httptest.NewRecorder()
+		httpu.SetDefaultOptions()
+		httpu.Write500IfErr(err, w, format, 1)
+
+		if w.Body.Len() != 0 {
+			t.Fatal("Was not expecting a body", w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package httpu
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// defaultResponseBufferSize is the size of the encoded response above which the encode
+// functions stream to the response instead of buffering
+const defaultResponseBufferSize = 1 << 20
+
+// maxPooledBufferSize is the capacity above which buffers are not returned to the pool,
+// so that one very large response does not pin its memory for the life of the process
+const maxPooledBufferSize = 4 << 20
+
+// bufferPool holds the buffers responses are encoded into
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer resets buf and returns it to the pool
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// responseBuffer is an io.Writer which holds an encoded response in memory until it is
+// committed. If more than limit bytes are written the buffered bytes are written to the
+// response, and all further writes go straight to the response.
+type responseBuffer struct {
+	buf         *bytes.Buffer
+	header      http.Header
+	limit       int
+	statusCode  int
+	streaming   bool
+	w           http.ResponseWriter
+	wroteHeader bool
+}
+
+// newResponseBuffer returns a responseBuffer for w that buffers up to limit bytes. If
+// limit is 0 or less the responseBuffer streams from the first write.
+func newResponseBuffer(w http.ResponseWriter, limit int) *responseBuffer {
+	return &responseBuffer{
+		buf:       getBuffer(),
+		limit:     limit,
+		streaming: limit <= 0,
+		w:         w,
+	}
+}
+
+func (rb *responseBuffer) Write(p []byte) (int, error) {
+	if rb.streaming {
+		rb.writeHeader()
+		return rb.w.Write(p)
+	}
+
+	if rb.buf.Len()+len(p) <= rb.limit {
+		return rb.buf.Write(p)
+	}
+
+	rb.streaming = true
+	rb.writeHeader()
+	_, err := rb.w.Write(rb.buf.Bytes())
+	rb.buf.Reset()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return rb.w.Write(p)
+}
+
+// commit writes the buffered response, along with its Content-Length, if the responseBuffer
+// has not already switched to streaming
+func (rb *responseBuffer) commit() error {
+	if rb.streaming {
+		return nil
+	}
+
+	rb.w.Header().Set("Content-Length", strconv.Itoa(rb.buf.Len()))
+	rb.writeHeader()
+	_, err := rb.w.Write(rb.buf.Bytes())
+
+	return err
+}
+
+// writeHeader adds the headers of the responseBuffer to the response and writes its status
+// code, if it has one, the first time it is called. The headers and status code are held
+// back until then so that they are not sent along with a HTTP 500 for a failed encoding.
+func (rb *responseBuffer) writeHeader() {
+	if rb.wroteHeader {
+		return
+	}
+
+	rb.wroteHeader = true
+	header := rb.w.Header()
+
+	for key, values := range rb.header {
+		header[key] = append([]string(nil), values...)
+	}
+
+	if rb.statusCode != 0 {
+		rb.w.WriteHeader(rb.statusCode)
+	}
+}
+
+// release returns the buffer of the responseBuffer to the pool. The responseBuffer cannot
+// be used afterwards.
+func (rb *responseBuffer) release() {
+	putBuffer(rb.buf)
+	rb.buf = nil
+}
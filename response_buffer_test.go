@@ -0,0 +1,116 @@
+package httpu_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clavoie/httpu"
+)
+
+func TestResponseBuffer(t *testing.T) {
+	format := "encode: %v"
+	errFormat := format + ": %v"
+	encodeErr := errors.New("encode error")
+	payload := strings.Repeat("x", 64)
+
+	// encoder writes src in two halves, failing after the first if fail is true
+	newEncoder := func(fail bool) httpu.Encoder {
+		return httpu.EncoderFunc(func(w io.Writer, src interface{}) error {
+			s := src.(string)
+			_, err := io.WriteString(w, s[:len(s)/2])
+
+			if err != nil || fail {
+				return encodeErr
+			}
+
+			_, err = io.WriteString(w, s[len(s)/2:])
+			return err
+		})
+	}
+	withEncoder := func(fail bool, size int) []httpu.Option {
+		return []httpu.Option{
+			httpu.WithEncoder("text/plain", newEncoder(fail)),
+			httpu.WithResponseBufferSize(size),
+		}
+	}
+
+	t.Run("Buffered", func(t *testing.T) {
+		r, w, _, i, finish := newTestImpl(t, ``, withEncoder(false, 1024)...)
+		defer finish()
+
+		r.Header.Set("Accept", "text/plain")
+
+		if i.EncodeOr500(payload, format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Body.String() != payload || w.Header().Get("Content-Length") != "64" {
+			t.Fatal("Unexpected response", w.Header(), w.Body.String())
+		}
+	})
+	t.Run("BufferedFail", func(t *testing.T) {
+		r, w, l, i, finish := newTestImpl(t, ``, withEncoder(true, 1024)...)
+		defer finish()
+
+		r.Header.Set("Accept", "text/plain")
+
+		l.EXPECT().Errorf(errFormat, 1, encodeErr)
+
+		if i.EncodeOr500(payload, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusInternalServerError || w.Body.Len() != 0 {
+			t.Fatal("Was expecting a clean 500", w.Code, w.Body.String())
+		}
+
+		if w.Header().Get("Content-Type") != "" {
+			t.Fatal("Was not expecting a content type", w.Header())
+		}
+	})
+	t.Run("Streamed", func(t *testing.T) {
+		r, w, _, i, finish := newTestImpl(t, ``, withEncoder(false, 40)...)
+		defer finish()
+
+		r.Header.Set("Accept", "text/plain")
+
+		if i.EncodeOr500(payload, format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Body.String() != payload || w.Header().Get("Content-Length") != "" {
+			t.Fatal("Unexpected response", w.Header(), w.Body.String())
+		}
+	})
+	t.Run("StreamedFail", func(t *testing.T) {
+		r, w, l, i, finish := newTestImpl(t, ``, withEncoder(true, 0)...)
+		defer finish()
+
+		r.Header.Set("Accept", "text/plain")
+
+		l.EXPECT().Errorf(errFormat, 1, encodeErr)
+
+		if i.EncodeOr500(payload, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusOK || w.Body.String() != payload[:32] {
+			t.Fatal("Was expecting the partial stream to be kept", w.Code, w.Body.String())
+		}
+	})
+	t.Run("EncodeJsonOr500", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, ``)
+		defer finish()
+
+		if i.EncodeJsonOr500(payload, format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Header().Get("Content-Length") != "67" {
+			t.Fatal("Unexpected content length", w.Header())
+		}
+	})
+}
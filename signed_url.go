@@ -0,0 +1,193 @@
+package httpu
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/clavoie/logu/v2"
+)
+
+// The query parameters added to signed urls
+const (
+	signedURLExpires   = "expires"
+	signedURLKeyID     = "kid"
+	signedURLSignature = "signature"
+)
+
+// SignedURLErr is returned when a signed url cannot be verified. Its status code is written
+// by WriteErr.
+type SignedURLErr struct {
+	reason     string
+	statusCode int
+}
+
+func (sue *SignedURLErr) Error() string {
+	return "httpu: " + sue.reason
+}
+
+// StatusCode returns the http status code to write for the error
+func (sue *SignedURLErr) StatusCode() int {
+	return sue.statusCode
+}
+
+var (
+	// ErrSignatureInvalid is returned when a url is not signed, is signed with an unknown key,
+	// or its signature does not match. Its status code is http.StatusForbidden.
+	ErrSignatureInvalid = &SignedURLErr{"url signature invalid", http.StatusForbidden}
+
+	// ErrURLExpired is returned when a url is correctly signed but has expired. Its status
+	// code is http.StatusGone.
+	ErrURLExpired = &SignedURLErr{"signed url expired", http.StatusGone}
+)
+
+// SigningKey is a secret key used to sign urls
+type SigningKey struct {
+	// ID identifies the key. It is added to each signed url so the key can be found when the
+	// url is verified.
+	ID string
+
+	// Secret is the HMAC secret of the key. It should be at least 32 random bytes.
+	Secret []byte
+}
+
+// URLSigner signs urls with an expiry time using HMAC-SHA256, and verifies them. A
+// URLSigner is safe for concurrent use.
+//
+// Keys are rotated by adding a new key to the front of the list of keys, and removing the
+// old key once every url signed with it has expired.
+type URLSigner struct {
+	keys []SigningKey
+}
+
+// NewURLSigner returns a new URLSigner for the keys. Urls are signed with the first key, and
+// a url signed with any of the keys is accepted. An error is returned if there are no keys,
+// or if a key has an empty ID or secret, or an ID used by another key.
+func NewURLSigner(keys ...SigningKey) (*URLSigner, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("httpu: NewURLSigner requires at least one key")
+	}
+
+	ids := make(map[string]bool, len(keys))
+	clone := make([]SigningKey, len(keys))
+
+	for index, key := range keys {
+		if key.ID == "" || len(key.Secret) == 0 {
+			return nil, fmt.Errorf("httpu: signing key %v has an empty id or secret", index)
+		}
+
+		if ids[key.ID] {
+			return nil, fmt.Errorf("httpu: signing key id %q is used more than once", key.ID)
+		}
+
+		ids[key.ID] = true
+		clone[index] = SigningKey{ID: key.ID, Secret: append([]byte(nil), key.Secret...)}
+	}
+
+	return &URLSigner{keys: clone}, nil
+}
+
+// Sign returns rawURL signed with the first key of the signer, valid until expires. The
+// path and query of the url are signed, while the scheme and host are not, so the url
+// remains valid behind proxies. Expiry, key id, and signature parameters are added to the
+// query of the url, and an error is returned if it already has one of them.
+//
+// bound holds values the url is bound to but which are not part of it, such as the id of
+// the user it was issued to. The same values must be passed to Verify for the url to be
+// accepted. bound may be nil.
+func (us *URLSigner) Sign(rawURL string, expires time.Time, bound url.Values) (string, error) {
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+
+	for _, name := range []string{signedURLExpires, signedURLKeyID, signedURLSignature} {
+		if _, has := query[name]; has {
+			return "", fmt.Errorf("httpu: url to sign already has a %q parameter", name)
+		}
+	}
+
+	key := us.keys[0]
+	query.Set(signedURLExpires, strconv.FormatInt(expires.Unix(), 10))
+	query.Set(signedURLKeyID, key.ID)
+	query.Set(signedURLSignature, urlSignature(key, u.EscapedPath(), query, bound))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// Verify checks that the url was signed by one of the keys of the signer with the same
+// bound values, and that it has not expired. ErrSignatureInvalid is returned if the
+// signature is missing or does not match, and ErrURLExpired if it has expired.
+func (us *URLSigner) Verify(u *url.URL, bound url.Values) error {
+	query := u.Query()
+	keyID := query.Get(signedURLKeyID)
+	signature := query.Get(signedURLSignature)
+	query.Del(signedURLSignature)
+
+	var key *SigningKey
+	for index := range us.keys {
+		if us.keys[index].ID == keyID {
+			key = &us.keys[index]
+			break
+		}
+	}
+
+	if key == nil || signature == "" {
+		return ErrSignatureInvalid
+	}
+
+	expected := urlSignature(*key, u.EscapedPath(), query, bound)
+	if hmac.Equal([]byte(signature), []byte(expected)) == false {
+		return ErrSignatureInvalid
+	}
+
+	expires, err := strconv.ParseInt(query.Get(signedURLExpires), 10, 64)
+
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+
+	if time.Now().Unix() >= expires {
+		return ErrURLExpired
+	}
+
+	return nil
+}
+
+// Handler returns an http.Handler that verifies the url of each request before passing it
+// on to next. Requests whose url cannot be verified are answered with a HTTP 403 or 410 by
+// an Impl created with the given options, logging to the go log package. Urls with bound
+// values must be verified with Impl.VerifySignedURL instead.
+func (us *URLSigner) Handler(next http.Handler, opts ...Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := NewImplWithOptions(w, r, logu.NewGoLogger(), opts...)
+
+		if i.VerifySignedURL(us, nil, "Could not verify signed url %v", r.URL.Path) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// urlSignature returns the signature of the path, query, and bound values with the key
+func urlSignature(key SigningKey, path string, query, bound url.Values) string {
+	mac := hmac.New(sha256.New, key.Secret)
+	fmt.Fprintf(mac, "%v\n%v\n%v", path, query.Encode(), bound.Encode())
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (i *impl) VerifySignedURL(signer *URLSigner, bound url.Values, format string, args ...interface{}) bool {
+	return i.WriteErr(signer.Verify(i.r.URL, bound), format, args...)
+}
@@ -0,0 +1,191 @@
+package httpu_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/clavoie/httpu"
+)
+
+func TestNewURLSigner(t *testing.T) {
+	tests := [][]httpu.SigningKey{
+		nil,
+		{{ID: "", Secret: []byte("secret")}},
+		{{ID: "a", Secret: nil}},
+		{{ID: "a", Secret: []byte("secret")}, {ID: "a", Secret: []byte("other")}},
+	}
+
+	for _, keys := range tests {
+		if _, err := httpu.NewURLSigner(keys...); err == nil {
+			t.Fatal("Was expecting an error", keys)
+		}
+	}
+}
+
+func TestURLSigner(t *testing.T) {
+	oldKey := httpu.SigningKey{ID: "old", Secret: []byte("old secret")}
+	newKey := httpu.SigningKey{ID: "new", Secret: []byte("new secret")}
+
+	newSigner := func(t *testing.T, keys ...httpu.SigningKey) *httpu.URLSigner {
+		signer, err := httpu.NewURLSigner(keys...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return signer
+	}
+
+	sign := func(t *testing.T, signer *httpu.URLSigner, rawURL string, expires time.Time, bound url.Values) *url.URL {
+		signed, err := signer.Sign(rawURL, expires, bound)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := url.Parse(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return u
+	}
+
+	signer := newSigner(t, oldKey)
+	expires := time.Now().Add(time.Hour)
+	bound := url.Values{"user": []string{"42"}}
+
+	t.Run("Verify", func(t *testing.T) {
+		u := sign(t, signer, "https://example.com/files/report.csv?name=report.csv", expires, bound)
+
+		if u.Host != "example.com" || u.Query().Get("name") != "report.csv" || u.Query().Get("kid") != "old" {
+			t.Fatal("Unexpected signed url", u)
+		}
+
+		if err := signer.Verify(u, bound); err != nil {
+			t.Fatal("Was not expecting an error", err)
+		}
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		u := sign(t, signer, "/files/report.csv", expires, nil)
+		rotated := newSigner(t, newKey, oldKey)
+
+		if err := rotated.Verify(u, nil); err != nil {
+			t.Fatal("Was expecting the old key to be accepted", err)
+		}
+
+		if kid := sign(t, rotated, "/files/report.csv", expires, nil).Query().Get("kid"); kid != "new" {
+			t.Fatal("Was expecting the new key to sign", kid)
+		}
+
+		if err := newSigner(t, newKey).Verify(u, nil); err != httpu.ErrSignatureInvalid {
+			t.Fatal("Was expecting the removed key to be rejected", err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		u := sign(t, signer, "/files/report.csv?name=report.csv", expires, bound)
+
+		tampered := []func(query url.Values){
+			func(query url.Values) { query.Set("name", "secret.csv") },
+			func(query url.Values) { query.Set("expires", "9999999999") },
+			func(query url.Values) { query.Del("signature") },
+			func(query url.Values) { query.Set("kid", "unknown") },
+		}
+
+		for index, tamper := range tampered {
+			copied := *u
+			query := copied.Query()
+			tamper(query)
+			copied.RawQuery = query.Encode()
+
+			if err := signer.Verify(&copied, bound); err != httpu.ErrSignatureInvalid {
+				t.Fatal("Was expecting an invalid signature", index, err)
+			}
+		}
+
+		if err := signer.Verify(u, url.Values{"user": []string{"43"}}); err != httpu.ErrSignatureInvalid {
+			t.Fatal("Was expecting an invalid signature for other bound values", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		u := sign(t, signer, "/files/report.csv", time.Now().Add(-time.Minute), nil)
+
+		if err := signer.Verify(u, nil); err != httpu.ErrURLExpired {
+			t.Fatal("Was expecting an expired url", err)
+		}
+	})
+
+	t.Run("ReservedParameter", func(t *testing.T) {
+		if _, err := signer.Sign("/files/report.csv?signature=1", expires, nil); err == nil {
+			t.Fatal("Was expecting an error")
+		}
+	})
+
+	t.Run("VerifySignedURL", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			url      *url.URL
+			bound    url.Values
+			expected int
+			err      error
+		}{
+			{"Valid", sign(t, signer, "/files/a", expires, bound), bound, http.StatusOK, nil},
+			{"Invalid", sign(t, signer, "/files/a", expires, bound), nil, http.StatusForbidden, httpu.ErrSignatureInvalid},
+			{"Expired", sign(t, signer, "/files/a", time.Now().Add(-time.Minute), bound), bound, http.StatusGone, httpu.ErrURLExpired},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				r, w, l, i, finish := newTestImpl(t, "")
+				defer finish()
+
+				r.URL = test.url
+				if test.err != nil {
+					l.EXPECT().Warningf(NonEmptyStr(), 1, test.err)
+				}
+
+				if i.VerifySignedURL(signer, test.bound, "verify: %v", 1) != (test.err != nil) {
+					t.Fatal("Unexpected result")
+				}
+
+				if w.Code != test.expected {
+					t.Fatal("Unexpected code", w.Code)
+				}
+			})
+		}
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		handler := signer.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+
+		for _, test := range []struct {
+			url      string
+			expected int
+		}{
+			{sign(t, signer, "/files/a", expires, nil).String(), http.StatusTeapot},
+			{"/files/a", http.StatusForbidden},
+		} {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.url, nil))
+
+			if w.Code != test.expected {
+				t.Fatal("Unexpected code", test.url, w.Code)
+			}
+		}
+	})
+
+	t.Run("ErrStatusCode", func(t *testing.T) {
+		var statusCoder interface{ StatusCode() int }
+
+		if errors.As(httpu.ErrURLExpired, &statusCoder) == false || statusCoder.StatusCode() != http.StatusGone {
+			t.Fatal("Was expecting a status code")
+		}
+	})
+}
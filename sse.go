@@ -0,0 +1,214 @@
+package httpu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHeartbeatInterval is how often an idle event stream sends a heartbeat comment
+const defaultHeartbeatInterval = 15 * time.Second
+
+// ErrStreamingUnsupported is returned when the http.ResponseWriter of a request cannot flush
+// partial responses, and so cannot stream events.
+var ErrStreamingUnsupported = errors.New("httpu: response writer does not support streaming")
+
+// Event is a server-sent event
+type Event struct {
+	// Event is the type of the event, which clients listen for with addEventListener. An
+	// empty type is delivered to onmessage.
+	Event string
+
+	// Id is the id of the event. Clients send the id of the last event they received in the
+	// Last-Event-ID header when they reconnect.
+	Id string
+
+	// Data is the payload of the event, which is json encoded the same way as
+	// EncodeJsonOr500. A nil Data sends an event without a data field.
+	Data interface{}
+
+	// Retry is how long clients wait before reconnecting if the stream is lost. It is only
+	// sent if it is more than 0.
+	Retry time.Duration
+}
+
+// EventStream writes server-sent events to a response. Its methods are safe for concurrent
+// use. An EventStream is created with Impl.EventStreamOr500 and must be closed once the
+// handler is done with it.
+type EventStream struct {
+	ctx         context.Context
+	flusher     http.Flusher
+	lastEventId string
+	stop        chan struct{}
+	stopOnce    sync.Once
+
+	mu  sync.Mutex
+	w   http.ResponseWriter
+	err error
+}
+
+// LastEventId returns the Last-Event-ID header of the request, the id of the last event the
+// client received before it reconnected, or an empty string if it is connecting for the
+// first time. Events after it should be sent to resume the stream.
+func (es *EventStream) LastEventId() string {
+	return es.lastEventId
+}
+
+// Done returns a channel that is closed when the client disconnects
+func (es *EventStream) Done() <-chan struct{} {
+	return es.ctx.Done()
+}
+
+// Send writes the event to the stream and flushes it to the client. The error of the
+// context of the request is returned once the client has disconnected, which ends the
+// stream. Any other error, such as a failure to encode Data, is returned as is.
+func (es *EventStream) Send(e *Event) error {
+	buf := new(bytes.Buffer)
+
+	if e.Event != "" {
+		buf.WriteString("event: " + sseField(e.Event) + "\n")
+	}
+
+	if e.Id != "" {
+		buf.WriteString("id: " + strings.ReplaceAll(sseField(e.Id), "\x00", "") + "\n")
+	}
+
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	if e.Data != nil {
+		data := new(bytes.Buffer)
+		err := encodeJson(data, e.Data)
+
+		if err != nil {
+			return err
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(data.String(), "\n"), "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+
+	buf.WriteString("\n")
+	return es.write(buf.Bytes())
+}
+
+// Comment writes a comment to the stream, which clients ignore. Comments keep idle
+// connections open through proxies, and are sent automatically as heartbeats.
+func (es *EventStream) Comment(text string) error {
+	buf := new(bytes.Buffer)
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		buf.WriteString(": " + strings.ReplaceAll(line, "\r", "") + "\n")
+	}
+
+	buf.WriteString("\n")
+	return es.write(buf.Bytes())
+}
+
+// Close stops the heartbeats of the stream, and waits for any heartbeat being written to
+// finish, so that the handler can safely return. The stream cannot be written to afterwards.
+func (es *EventStream) Close() error {
+	es.stopOnce.Do(func() { close(es.stop) })
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if es.err == nil {
+		es.err = errors.New("httpu: event stream closed")
+	}
+
+	return nil
+}
+
+// write writes p to the stream and flushes it
+func (es *EventStream) write(p []byte) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if err := es.ctx.Err(); err != nil && es.err == nil {
+		es.err = err
+	}
+
+	if es.err != nil {
+		return es.err
+	}
+
+	_, es.err = es.w.Write(p)
+
+	if es.err == nil {
+		es.flusher.Flush()
+	}
+
+	return es.err
+}
+
+// heartbeat sends a heartbeat comment every interval until the stream is closed or the
+// client disconnects
+func (es *EventStream) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if es.Comment("heartbeat") != nil {
+				return
+			}
+		case <-es.stop:
+			return
+		case <-es.ctx.Done():
+			return
+		}
+	}
+}
+
+// sseField removes the line breaks from the value of an event field, which would otherwise
+// end the field early
+func sseField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+func (i *impl) EventStreamOr500(format string, args ...interface{}) (*EventStream, bool) {
+	flusher, isFlusher := i.w.(http.Flusher)
+
+	if isFlusher == false {
+		return nil, i.Write500IfErr(ErrStreamingUnsupported, format, args...)
+	}
+
+	ctx := context.Background()
+	var lastEventId string
+
+	if i.r != nil {
+		ctx = i.r.Context()
+		lastEventId = i.r.Header.Get("Last-Event-ID")
+	}
+
+	header := i.w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	i.w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	es := &EventStream{
+		ctx:         ctx,
+		flusher:     flusher,
+		lastEventId: lastEventId,
+		stop:        make(chan struct{}),
+		w:           i.w,
+	}
+
+	if i.o.heartbeatInterval > 0 {
+		go es.heartbeat(i.o.heartbeatInterval)
+	}
+
+	return es, false
+}
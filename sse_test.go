@@ -0,0 +1,143 @@
+package httpu_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clavoie/httpu"
+)
+
+func TestEventStream(t *testing.T) {
+	format := "events: %v"
+
+	t.Run("Send", func(t *testing.T) {
+		r, w, _, i, finish := newTestImpl(t, "", httpu.WithHeartbeatInterval(0))
+		defer finish()
+
+		r.Header.Set("Last-Event-ID", "41")
+
+		stream, failed := i.EventStreamOr500(format, 1)
+		if failed {
+			t.Fatal("Was not expecting an error")
+		}
+
+		defer stream.Close()
+
+		if stream.LastEventId() != "41" {
+			t.Fatal("Unexpected last event id", stream.LastEventId())
+		}
+
+		header := w.Header()
+		if w.Code != http.StatusOK || header.Get("Content-Type") != "text/event-stream" || header.Get("Cache-Control") != "no-cache" || w.Flushed == false {
+			t.Fatal("Unexpected response", w.Code, header, w.Flushed)
+		}
+
+		err := stream.Send(&httpu.Event{Event: "update\nid: 7", Id: "42", Data: map[string]string{"Name": "<a>\nb"}, Retry: 3 * time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = stream.Send(&httpu.Event{Event: "ping"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = stream.Comment("a\nb")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "event: updateid: 7\nid: 42\nretry: 3000\ndata: {\"Name\":\"\\u003ca\\u003e\\nb\"}\n\n" +
+			"event: ping\n\n" +
+			": a\n: b\n\n"
+
+		if w.Body.String() != expected {
+			t.Fatalf("Unexpected body %q", w.Body.String())
+		}
+	})
+
+	t.Run("EncodeErr", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, "", httpu.WithHeartbeatInterval(0))
+		defer finish()
+
+		stream, _ := i.EventStreamOr500(format, 1)
+		defer stream.Close()
+
+		if stream.Send(&httpu.Event{Data: make(chan int)}) == nil || w.Body.Len() != 0 {
+			t.Fatal("Was expecting an error", w.Body.String())
+		}
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, "", httpu.WithHeartbeatInterval(time.Millisecond))
+		defer finish()
+
+		stream, _ := i.EventStreamOr500(format, 1)
+		time.Sleep(20 * time.Millisecond)
+		stream.Close()
+
+		if strings.HasPrefix(w.Body.String(), ": heartbeat\n\n") == false {
+			t.Fatalf("Unexpected body %q", w.Body.String())
+		}
+
+		if stream.Send(&httpu.Event{Event: "late"}) == nil {
+			t.Fatal("Was expecting an error after close")
+		}
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+		r, _, l, _, finish := newTestImpl(t, "")
+		defer finish()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		w := httptest.NewRecorder()
+		i := httpu.NewImplWithOptions(w, r.WithContext(ctx), l)
+
+		stream, _ := i.EventStreamOr500(format, 1)
+		defer stream.Close()
+
+		cancel()
+		<-stream.Done()
+
+		if err := stream.Send(&httpu.Event{Event: "gone"}); err != context.Canceled {
+			t.Fatal("Was expecting the context error", err)
+		}
+
+		if strings.Contains(w.Body.String(), "gone") {
+			t.Fatal("Was not expecting the event to be written", w.Body.String())
+		}
+	})
+
+	t.Run("NotFlusher", func(t *testing.T) {
+		r, w, l, _, finish := newTestImpl(t, "")
+		defer finish()
+
+		l.EXPECT().Errorf(NonEmptyStr(), 1, httpu.ErrStreamingUnsupported)
+		i := httpu.NewImplWithOptions(struct{ http.ResponseWriter }{w}, r, l)
+
+		stream, failed := i.EventStreamOr500(format, 1)
+		if failed == false || stream != nil || w.Code != http.StatusInternalServerError {
+			t.Fatal("Was expecting an error", w.Code)
+		}
+	})
+
+	t.Run("Package", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://test.com/events", nil)
+		w := httptest.NewRecorder()
+
+		stream, failed := httpu.EventStreamOr500(w, r, format, 1)
+		if failed {
+			t.Fatal("Was not expecting an error")
+		}
+
+		stream.Close()
+
+		if w.Header().Get("Content-Type") != "text/event-stream" {
+			t.Fatal("Unexpected header", w.Header())
+		}
+	})
+}
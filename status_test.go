@@ -0,0 +1,164 @@
+package httpu_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clavoie/httpu"
+)
+
+func TestStatusAndHeaders(t *testing.T) {
+	type Json struct {
+		Field string
+	}
+
+	src := &Json{"value"}
+
+	t.Run("WithStatusCode", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		opts := []httpu.Option{
+			httpu.WithStatusCode(http.StatusTeapot),
+			httpu.WithHeader("x-total-count", "10"),
+			httpu.WithHeader("X-Removed", "1"),
+			httpu.WithHeader("X-Removed", ""),
+		}
+
+		if i.With(opts...).EncodeJsonOr500(src, "encode") {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Code != http.StatusTeapot || w.Header().Get("X-Total-Count") != "10" || w.Header().Get("X-Removed") != "" {
+			t.Fatal("Unexpected response", w.Code, w.Header())
+		}
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, "", httpu.WithResponseBufferSize(4))
+		defer finish()
+
+		if i.With(httpu.WithStatusCode(http.StatusCreated), httpu.WithHeader("X-Id", "1")).EncodeJsonOr500(src, "encode") {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Code != http.StatusCreated || w.Header().Get("X-Id") != "1" || w.Body.String() != "{\"Field\":\"value\"}\n" {
+			t.Fatal("Unexpected response", w.Code, w.Header(), w.Body.String())
+		}
+	})
+
+	t.Run("EncodeFailure", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		l.EXPECT().Errorf(NonEmptyStr(), NonEmptyStr())
+		failing := httpu.WithEncoder("application/json", httpu.EncoderFunc(func(w io.Writer, src interface{}) error {
+			return errors.New("encode failed")
+		}))
+
+		if i.With(failing).EncodeCreatedOr500("/widgets/1", src, "encode") == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusInternalServerError || w.Header().Get("Location") != "" {
+			t.Fatal("Unexpected response", w.Code, w.Header())
+		}
+	})
+
+	t.Run("EncodeCreatedOr500", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		if i.EncodeCreatedOr500("/widgets/1", src, "encode") {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Code != http.StatusCreated || w.Header().Get("Location") != "/widgets/1" || w.Header().Get("Content-Type") != "application/json" {
+			t.Fatal("Unexpected response", w.Code, w.Header())
+		}
+	})
+
+	t.Run("NotAcceptable", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			encode func(i httpu.Impl) bool
+			status int
+		}{
+			{"Created", func(i httpu.Impl) bool { return i.EncodeCreatedOr500("/widgets/1", src, "encode") }, http.StatusCreated},
+			{"Accepted", func(i httpu.Impl) bool { return i.EncodeAcceptedOr500("/jobs/1", src, "encode") }, http.StatusAccepted},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				r, w, _, i, finish := newTestImpl(t, "")
+				defer finish()
+
+				r.Header.Set("Accept", "image/png")
+
+				if test.encode(i) {
+					t.Fatal("Was not expecting an error")
+				}
+
+				if w.Code != test.status || w.Header().Get("Location") == "" || w.Header().Get("Content-Type") != "application/json" {
+					t.Fatal("Unexpected response", w.Code, w.Header())
+				}
+			})
+		}
+	})
+
+	t.Run("NoLocation", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		l.EXPECT().Warningf(NonEmptyStr(), 1, httpu.ErrNoLocation)
+
+		if i.EncodeCreatedOr500("", src, "encode %v", 1) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Code != http.StatusCreated || w.Header().Get("Location") != "" {
+			t.Fatal("Unexpected response", w.Code, w.Header())
+		}
+	})
+
+	t.Run("EncodeAcceptedOr500", func(t *testing.T) {
+		tests := []struct {
+			monitor string
+		}{
+			{"/jobs/1"},
+			{""},
+		}
+
+		for _, test := range tests {
+			r := httptest.NewRequest("POST", "http://test.com/jobs", nil)
+			w := httptest.NewRecorder()
+
+			if httpu.EncodeAcceptedOr500(w, r, test.monitor, src, "encode") {
+				t.Fatal("Was not expecting an error")
+			}
+
+			if w.Code != http.StatusAccepted || w.Header().Get("Location") != test.monitor {
+				t.Fatal("Unexpected response", w.Code, w.Header())
+			}
+		}
+	})
+
+	t.Run("NotConditional", func(t *testing.T) {
+		r, w, _, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		r.Method = "GET"
+		r.Header.Set("If-None-Match", "*")
+
+		if i.With(httpu.WithETagValue("v1"), httpu.WithStatusCode(http.StatusAccepted)).EncodeJsonOr500(src, "encode") {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Code != http.StatusAccepted {
+			t.Fatal("Unexpected code", w.Code)
+		}
+	})
+}
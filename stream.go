@@ -0,0 +1,275 @@
+package httpu
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StreamErrTrailer is the trailer set on a streamed response that failed part way through.
+// Its value is the status code and text of the failure, such as
+// "500 Internal Server Error". The error itself is only logged.
+const StreamErrTrailer = "X-Stream-Error"
+
+// defaultStreamFlushInterval is the longest a streamed item is held before being flushed to
+// the client
+const defaultStreamFlushInterval = 100 * time.Millisecond
+
+// StreamIterator returns the next item of a streamed response, or io.EOF once there are no
+// more items
+type StreamIterator func() (interface{}, error)
+
+// StreamChan returns a StreamIterator over the items received from ch, which returns io.EOF
+// once ch is closed. If ctx is done before an item is received, the error of ctx is returned.
+// ctx is usually the context of the request, so that a client going away stops the stream.
+func StreamChan[T any](ctx context.Context, ch <-chan T) StreamIterator {
+	return func() (interface{}, error) {
+		select {
+		case item, isOpen := <-ch:
+			if isOpen == false {
+				return nil, io.EOF
+			}
+
+			return item, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// streamFraming is the text written around and between the items of a streamed response
+type streamFraming struct {
+	contentType string
+	start       string
+	separator   string
+	suffix      string
+	end         string
+}
+
+var (
+	// ndjsonFraming writes each item on its own line
+	ndjsonFraming = streamFraming{contentType: "application/x-ndjson", suffix: "\n"}
+
+	// jsonArrayFraming writes the items as the elements of a json array
+	jsonArrayFraming = streamFraming{contentType: "application/json", start: "[", separator: ",", end: "]\n"}
+)
+
+func (i *impl) StreamJsonArrayOr500(next StreamIterator, format string, args ...interface{}) bool {
+	return i.stream(jsonArrayFraming, next, format, args...)
+}
+
+func (i *impl) StreamNdjsonOr500(next StreamIterator, format string, args ...interface{}) bool {
+	return i.stream(ndjsonFraming, next, format, args...)
+}
+
+// stream json encodes each item returned from next to the response, framed by framing. The
+// response is flushed at least as often as the interval set by WithStreamFlushInterval,
+// including while next is blocked waiting for the next item.
+func (i *impl) stream(framing streamFraming, next StreamIterator, format string, args ...interface{}) bool {
+	ctx := context.Background()
+	if i.r != nil {
+		ctx = i.r.Context()
+	}
+
+	var w http.ResponseWriter = i.w
+
+	if i.o.compression {
+		compressWriter := newCompressWriter(i.w, i.r, i.o)
+		defer compressWriter.Close()
+		w = compressWriter
+	}
+
+	header := i.w.Header()
+	header.Set("Content-Type", framing.contentType)
+	header.Add("Trailer", StreamErrTrailer)
+
+	cw := &commitWriter{w: w}
+	sw := &streamWriter{w: w, bw: bufio.NewWriter(cw), interval: i.o.streamFlushInterval}
+	sw.bw.WriteString(framing.start)
+	sw.start()
+
+	var err error
+
+	for count := 0; ; count++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+
+		var item interface{}
+		item, err = next()
+
+		if err == io.EOF {
+			err = nil
+			break
+		}
+
+		if err != nil {
+			break
+		}
+
+		var data []byte
+		data, err = json.Marshal(item)
+
+		if err != nil {
+			break
+		}
+
+		writeErr := sw.write(func(bw *bufio.Writer) {
+			if count > 0 {
+				bw.WriteString(framing.separator)
+			}
+
+			bw.Write(data)
+			bw.WriteString(framing.suffix)
+		})
+
+		if writeErr != nil {
+			sw.stop()
+			i.logErr(writeErr, http.StatusOK, format, args...)
+			return true
+		}
+	}
+
+	sw.stop()
+
+	switch {
+	case err == nil:
+		sw.bw.WriteString(framing.end)
+
+		if writeErr := sw.flush(); writeErr != nil {
+			i.logErr(writeErr, http.StatusOK, format, args...)
+			return true
+		}
+
+		return false
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// the client went away, which is not a failure of the handler
+		i.logErr(err, http.StatusOK, format, args...)
+		return true
+	case cw.committed == false:
+		header.Del("Content-Type")
+		header.Del("Trailer")
+		return i.Write500IfErr(err, format, args...)
+	}
+
+	// the end of the framing is left off so that clients cannot mistake the partial response
+	// for a complete one
+	sw.flush()
+	header.Set(StreamErrTrailer, strconv.Itoa(http.StatusInternalServerError)+" "+http.StatusText(http.StatusInternalServerError))
+	i.logErr(err, http.StatusInternalServerError, format, args...)
+
+	return true
+}
+
+// streamWriter buffers the items of a streamed response, flushing them to the client at
+// least once every interval. Items are flushed as they are written if the last flush was an
+// interval or more ago, and a background goroutine flushes any items still held once the
+// interval passes, so that items are not held while the producer of the next item blocks.
+type streamWriter struct {
+	w        http.ResponseWriter
+	bw       *bufio.Writer
+	interval time.Duration
+
+	mu        sync.Mutex
+	pending   bool
+	lastFlush time.Time
+	err       error
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+// start starts flushing held items in the background, if there is a flush interval
+func (sw *streamWriter) start() {
+	if sw.interval <= 0 {
+		return
+	}
+
+	sw.done = make(chan struct{})
+	sw.stopped = make(chan struct{})
+
+	go func() {
+		defer close(sw.stopped)
+
+		ticker := time.NewTicker(sw.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				sw.mu.Lock()
+
+				if sw.pending && sw.err == nil && time.Since(sw.lastFlush) >= sw.interval {
+					sw.err = sw.flushLocked()
+				}
+
+				sw.mu.Unlock()
+			case <-sw.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops flushing in the background and waits for any flush in progress to finish, after
+// which the streamWriter is only used by the calling goroutine
+func (sw *streamWriter) stop() {
+	if sw.done == nil {
+		return
+	}
+
+	close(sw.done)
+	<-sw.stopped
+	sw.done = nil
+}
+
+// write writes to the buffer with fn, flushing it if the last flush was an interval or more
+// ago. The error of any failed flush, including one made in the background, is returned.
+func (sw *streamWriter) write(fn func(bw *bufio.Writer)) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.err != nil {
+		return sw.err
+	}
+
+	fn(sw.bw)
+	sw.pending = true
+
+	if time.Since(sw.lastFlush) >= sw.interval {
+		sw.err = sw.flushLocked()
+	}
+
+	return sw.err
+}
+
+// flush writes everything held to the client
+func (sw *streamWriter) flush() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.err != nil {
+		return sw.err
+	}
+
+	sw.err = sw.flushLocked()
+	return sw.err
+}
+
+// flushLocked writes everything held to the client. sw.mu must be held.
+func (sw *streamWriter) flushLocked() error {
+	sw.pending = false
+	sw.lastFlush = time.Now()
+	err := sw.bw.Flush()
+
+	if flusher, isFlusher := sw.w.(http.Flusher); isFlusher && err == nil {
+		flusher.Flush()
+	}
+
+	return err
+}
@@ -0,0 +1,231 @@
+package httpu_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clavoie/httpu"
+)
+
+// flushRecorder is a httptest.ResponseRecorder that records what has been flushed to the
+// client, which can be read while the response is being written
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	mu      sync.Mutex
+	flushed string
+}
+
+func (fr *flushRecorder) Flush() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.ResponseRecorder.Flush()
+	fr.flushed = fr.Body.String()
+}
+
+// flushedBody returns the body of the response as of the last flush
+func (fr *flushRecorder) flushedBody() string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.flushed
+}
+
+// streamItems returns a StreamIterator over the items, returning err once they run out
+func streamItems(err error, items ...interface{}) httpu.StreamIterator {
+	return func() (interface{}, error) {
+		if len(items) == 0 {
+			return nil, err
+		}
+
+		item := items[0]
+		items = items[1:]
+		return item, nil
+	}
+}
+
+func TestStream(t *testing.T) {
+	type Row struct {
+		Id int
+	}
+
+	format := "stream: %v"
+
+	t.Run("Ndjson", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, "", httpu.WithStreamFlushInterval(0))
+		defer finish()
+
+		if i.StreamNdjsonOr500(streamItems(io.EOF, &Row{1}, &Row{2}, &Row{3}), format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Body.String() != "{\"Id\":1}\n{\"Id\":2}\n{\"Id\":3}\n" || w.Header().Get("Content-Type") != "application/x-ndjson" {
+			t.Fatal("Unexpected response", w.Header(), w.Body.String())
+		}
+
+		if w.Flushed == false || w.Result().Trailer.Get(httpu.StreamErrTrailer) != "" {
+			t.Fatal("Unexpected flush or trailer", w.Flushed, w.Result().Trailer)
+		}
+	})
+
+	t.Run("JsonArray", func(t *testing.T) {
+		tests := []struct {
+			items    []interface{}
+			expected int
+		}{
+			{[]interface{}{&Row{1}, &Row{2}}, 2},
+			{nil, 0},
+		}
+
+		for _, test := range tests {
+			_, w, _, i, finish := newTestImpl(t, "")
+
+			if i.StreamJsonArrayOr500(streamItems(io.EOF, test.items...), format, 1) {
+				t.Fatal("Was not expecting an error")
+			}
+
+			var rows []*Row
+			if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil || len(rows) != test.expected || w.Header().Get("Content-Type") != "application/json" {
+				t.Fatal("Unexpected response", err, w.Body.String())
+			}
+
+			finish()
+		}
+	})
+
+	t.Run("SlowProducer", func(t *testing.T) {
+		r, _, l, _, finish := newTestImpl(t, "")
+		defer finish()
+
+		w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		i := httpu.NewImplWithOptions(w, r, l, httpu.WithStreamFlushInterval(50*time.Millisecond))
+		items := streamItems(io.EOF, 1, 2, 3)
+		calls := 0
+
+		next := func() (interface{}, error) {
+			calls++
+
+			if calls < 4 {
+				return items()
+			}
+
+			// the producer blocks, and what it has already produced should still reach the client
+			deadline := time.Now().Add(300 * time.Millisecond)
+			for w.flushedBody() != "1\n2\n3\n" && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			if actual := w.flushedBody(); actual != "1\n2\n3\n" {
+				t.Errorf("Unexpected flushed body %q", actual)
+			}
+
+			return items()
+		}
+
+		if i.StreamNdjsonOr500(next, format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+	})
+
+	t.Run("StreamChan", func(t *testing.T) {
+		ch := make(chan *Row, 2)
+		ch <- &Row{1}
+		ch <- &Row{2}
+		close(ch)
+
+		r := httptest.NewRequest("GET", "http://test.com/rows", nil)
+		w := httptest.NewRecorder()
+
+		if httpu.StreamJsonArrayOr500(w, r, httpu.StreamChan(r.Context(), ch), format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Body.String() != "[{\"Id\":1},{\"Id\":2}]\n" {
+			t.Fatal("Unexpected body", w.Body.String())
+		}
+	})
+
+	t.Run("StreamChanCancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := httpu.StreamChan(ctx, make(chan int))(); err != context.Canceled {
+			t.Fatal("Was expecting the context error", err)
+		}
+	})
+
+	t.Run("FailureBeforeCommit", func(t *testing.T) {
+		tests := []struct {
+			name string
+			next httpu.StreamIterator
+		}{
+			{"Iterator", streamItems(errors.New("query failed"))},
+			{"Encode", streamItems(io.EOF, make(chan int))},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, w, l, i, finish := newTestImpl(t, "")
+				defer finish()
+
+				l.EXPECT().Errorf(NonEmptyStr(), 1, NonEmptyStr())
+
+				if i.StreamNdjsonOr500(test.next, format, 1) == false {
+					t.Fatal("Was expecting an error")
+				}
+
+				if w.Code != http.StatusInternalServerError || w.Header().Get("Trailer") != "" || w.Header().Get("Content-Type") == "application/x-ndjson" {
+					t.Fatal("Unexpected response", w.Code, w.Header())
+				}
+			})
+		}
+	})
+
+	t.Run("FailureAfterCommit", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, "", httpu.WithStreamFlushInterval(0))
+		defer finish()
+
+		l.EXPECT().Errorf(NonEmptyStr(), 1, NonEmptyStr())
+
+		if i.StreamJsonArrayOr500(streamItems(errors.New("query failed"), &Row{1}), format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		resp := w.Result()
+		if resp.StatusCode != http.StatusOK || w.Body.String() != "[{\"Id\":1}" {
+			t.Fatal("Unexpected response", resp.StatusCode, w.Body.String())
+		}
+
+		if resp.Trailer.Get(httpu.StreamErrTrailer) != "500 Internal Server Error" {
+			t.Fatal("Unexpected trailer", resp.Trailer)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		r, _, l, _, finish := newTestImpl(t, "")
+		defer finish()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		r = r.WithContext(ctx)
+		w := httptest.NewRecorder()
+		i := httpu.NewImplWithOptions(w, r, l)
+
+		l.EXPECT().Warningf(NonEmptyStr(), 1, context.Canceled)
+
+		next := func() (interface{}, error) {
+			cancel()
+			return &Row{1}, nil
+		}
+
+		if i.StreamNdjsonOr500(next, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+	})
+}
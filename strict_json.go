@@ -0,0 +1,332 @@
+package httpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// StrictJsonErr is returned when a request body is rejected by strict json decoding. The
+// message of the error is written to the response, so it only ever contains details of the
+// request body itself.
+type StrictJsonErr struct {
+	// Field is the dotted path of the offending field, such as "a.b[2].c". Field is empty
+	// if the error does not relate to a field, as is the case with trailing data.
+	Field string
+
+	// Offset is the byte offset into the request body at which the problem was found. For
+	// unknown fields and duplicate keys it is the offset of the opening quote of the key.
+	Offset int64
+
+	// Reason is a short description of the problem
+	Reason string
+}
+
+func (sje *StrictJsonErr) Error() string {
+	if sje.Field == "" {
+		return fmt.Sprintf("%v at offset %v", sje.Reason, sje.Offset)
+	}
+
+	return fmt.Sprintf("%v %q at offset %v", sje.Reason, sje.Field, sje.Offset)
+}
+
+// unknownFieldPrefix is the prefix of the error message returned by encoding/json when
+// Decoder.DisallowUnknownFields is set and an unknown field is found
+const unknownFieldPrefix = "json: unknown field "
+
+// decodeStrictJson decodes a single json value from r into dst. The value may not contain
+// fields unknown to dst, objects may not contain duplicate keys, and no data other than
+// whitespace may follow the value.
+func decodeStrictJson(r io.Reader, dst interface{}) error {
+	data, err := io.ReadAll(r)
+
+	if err != nil {
+		return err
+	}
+
+	err = checkStrictJson(data, reflect.TypeOf(dst))
+
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(dst)
+
+	if err != nil && strings.HasPrefix(err.Error(), unknownFieldPrefix) {
+		field := strings.TrimPrefix(err.Error(), unknownFieldPrefix)
+		unquoted, unquoteErr := unquoteField(field)
+
+		if unquoteErr == nil {
+			field = unquoted
+		}
+
+		locateDecoder := json.NewDecoder(bytes.NewReader(data))
+		strictErr, locateErr := locateUnknownField(locateDecoder, data, reflect.TypeOf(dst), field, "")
+
+		if locateErr == nil && strictErr != nil {
+			return strictErr
+		}
+
+		return &StrictJsonErr{Field: field, Offset: decoder.InputOffset(), Reason: "unknown field"}
+	}
+
+	return err
+}
+
+// locateUnknownField reads the next json value from the decoder alongside t, the type the
+// value was decoded into, and returns an error naming the path and offset of the first key
+// named name that t has no field for. encoding/json only reports the name of an unknown
+// field, so its position is found by following the same rules encoding/json uses to match
+// keys to fields. nil is returned if no such key is found.
+func locateUnknownField(decoder *json.Decoder, data []byte, t reflect.Type, name, path string) (*StrictJsonErr, error) {
+	t = strictJsonType(t)
+	token, err := decoder.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		for decoder.More() {
+			offset := keyOffset(data, decoder.InputOffset())
+			token, err = decoder.Token()
+
+			if err != nil {
+				return nil, err
+			}
+
+			key, _ := token.(string)
+			field := joinFieldPath(path, key)
+			var fieldType reflect.Type
+
+			switch {
+			case t == nil:
+			case t.Kind() == reflect.Map:
+				fieldType = t.Elem()
+			case t.Kind() == reflect.Struct:
+				var isKnown bool
+				fieldType, _, isKnown = structFieldType(t, key)
+
+				if isKnown == false && key == name {
+					return &StrictJsonErr{Field: field, Offset: offset, Reason: "unknown field"}, nil
+				}
+			}
+
+			strictErr, err := locateUnknownField(decoder, data, fieldType, name, field)
+
+			if strictErr != nil || err != nil {
+				return strictErr, err
+			}
+		}
+	case json.Delim('['):
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+
+		for index := 0; decoder.More(); index++ {
+			strictErr, err := locateUnknownField(decoder, data, elemType, name, fmt.Sprintf("%v[%v]", path, index))
+
+			if strictErr != nil || err != nil {
+				return strictErr, err
+			}
+		}
+	default:
+		return nil, nil
+	}
+
+	// closing delimiter
+	_, err = decoder.Token()
+	return nil, err
+}
+
+// jsonUnmarshalerType is the reflect.Type of json.Unmarshaler
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// strictJsonType returns t with its pointers removed, or nil if the fields of values of t are
+// not checked by encoding/json, as is the case for interfaces and json.Unmarshalers
+func strictJsonType(t reflect.Type) reflect.Type {
+	for t != nil {
+		if t.Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+			return nil
+		}
+
+		switch t.Kind() {
+		case reflect.Ptr:
+			t = t.Elem()
+		case reflect.Interface:
+			return nil
+		default:
+			return t
+		}
+	}
+
+	return nil
+}
+
+// structFieldType returns the type and Go name of the field of the struct type t that
+// encoding/json decodes key into. Fields are matched by their json tag, or by their name if
+// they have none, ignoring case, and the fields of embedded structs are included, named by
+// their path from t such as "Embedded.Field".
+func structFieldType(t reflect.Type, key string) (reflect.Type, string, bool) {
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		name, isTagged := jsonFieldName(field)
+
+		if name == "-" && field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		if field.Anonymous && isTagged == false {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				if fieldType, goName, isKnown := structFieldType(embedded, key); isKnown {
+					return fieldType, field.Name + "." + goName, true
+				}
+
+				continue
+			}
+		}
+
+		if field.IsExported() == false {
+			continue
+		}
+
+		if strings.EqualFold(name, key) {
+			return field.Type, field.Name, true
+		}
+	}
+
+	return nil, "", false
+}
+
+// keyOffset returns the offset of the opening quote of the key that follows offset in data,
+// skipping the whitespace and comma before it
+func keyOffset(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\n', '\r', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+
+	return offset
+}
+
+// unquoteField removes the quotes encoding/json places around field names in its errors
+func unquoteField(field string) (string, error) {
+	var unquoted string
+	err := json.Unmarshal([]byte(field), &unquoted)
+
+	return unquoted, err
+}
+
+// checkStrictJson walks the tokens of data alongside t, the type data is decoded into,
+// returning an error if an object contains a duplicate key or if anything follows the first
+// json value
+func checkStrictJson(data []byte, t reflect.Type) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := checkStrictJsonValue(decoder, data, t, "")
+
+	if err != nil {
+		return err
+	}
+
+	offset := decoder.InputOffset()
+	_, err = decoder.Token()
+
+	if err == io.EOF {
+		return nil
+	}
+
+	return &StrictJsonErr{Offset: offset, Reason: "unexpected data after json value"}
+}
+
+// checkStrictJsonValue reads the next json value from the decoder alongside t, returning an
+// error if any object within the value has a duplicate key. When an object is decoded into a
+// struct, keys that encoding/json matches to the same field, such as "Field" and "field",
+// are duplicates.
+func checkStrictJsonValue(decoder *json.Decoder, data []byte, t reflect.Type, path string) error {
+	t = strictJsonType(t)
+	token, err := decoder.Token()
+
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		keys := make(map[string]bool)
+		fields := make(map[string]bool)
+
+		for decoder.More() {
+			offset := keyOffset(data, decoder.InputOffset())
+			token, err = decoder.Token()
+
+			if err != nil {
+				return err
+			}
+
+			key, _ := token.(string)
+			field := joinFieldPath(path, key)
+			var fieldType reflect.Type
+			var goName string
+			var isKnown bool
+
+			switch {
+			case t == nil:
+			case t.Kind() == reflect.Map:
+				fieldType = t.Elem()
+			case t.Kind() == reflect.Struct:
+				fieldType, goName, isKnown = structFieldType(t, key)
+			}
+
+			if keys[key] || (isKnown && fields[goName]) {
+				return &StrictJsonErr{Field: field, Offset: offset, Reason: "duplicate key"}
+			}
+
+			keys[key] = true
+			if isKnown {
+				fields[goName] = true
+			}
+
+			err = checkStrictJsonValue(decoder, data, fieldType, field)
+
+			if err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+
+		for index := 0; decoder.More(); index++ {
+			err = checkStrictJsonValue(decoder, data, elemType, fmt.Sprintf("%v[%v]", path, index))
+
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+
+	// closing delimiter
+	_, err = decoder.Token()
+	return err
+}
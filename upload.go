@@ -0,0 +1,116 @@
+package httpu
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+)
+
+// sniffLen is the number of bytes read from the start of a file to sniff its content type
+const sniffLen = 512
+
+// Upload is a file uploaded as part of a multipart form
+type Upload struct {
+	// Field is the name of the form field the file was uploaded under
+	Field string
+
+	// Filename is the name of the file as sent by the client. It must not be trusted as a
+	// path on disk.
+	Filename string
+
+	// Size is the size of the file in bytes
+	Size int64
+
+	// ContentType is the content type sniffed from the first bytes of the file, as
+	// opposed to the content type declared by the client in Header
+	ContentType string
+
+	// Header is the MIME header of the part of the form holding the file
+	Header textproto.MIMEHeader
+
+	fileHeader *multipart.FileHeader
+}
+
+// Open opens the contents of the uploaded file. The caller must close the file.
+func (u *Upload) Open() (multipart.File, error) {
+	return u.fileHeader.Open()
+}
+
+// CopyTo streams the contents of the uploaded file to w, returning the number of bytes
+// copied.
+func (u *Upload) CopyTo(w io.Writer) (int64, error) {
+	file, err := u.Open()
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+	return io.Copy(w, file)
+}
+
+// newUpload returns the Upload for a file in the form field, sniffing its content type with
+// the sniffers before the built in sniffing
+func newUpload(field string, fileHeader *multipart.FileHeader, sniffers []Sniffer) (*Upload, error) {
+	upload := &Upload{
+		Field:      field,
+		Filename:   fileHeader.Filename,
+		Size:       fileHeader.Size,
+		Header:     fileHeader.Header,
+		fileHeader: fileHeader,
+	}
+
+	file, err := upload.Open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+	upload.ContentType, _, err = sniff(file, sniffers)
+
+	return upload, err
+}
+
+func (i *impl) ForEachUpload(fn func(u *Upload) error, format string, args ...interface{}) bool {
+	statusCode, err := i.parseMultipartForm()
+
+	if i.WriteIfErr(err, statusCode, format, args...) {
+		return true
+	}
+
+	defer i.removeMultipartForm()
+
+	fields := make([]string, 0, len(i.r.MultipartForm.File))
+	for field := range i.r.MultipartForm.File {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		for _, fileHeader := range i.r.MultipartForm.File[field] {
+			upload, err := newUpload(field, fileHeader, i.o.uploadPolicy.sniffers())
+
+			if i.Write500IfErr(err, format, args...) {
+				return true
+			}
+
+			err = i.o.uploadPolicy.check(upload.Filename, upload.Header, upload.ContentType)
+
+			if i.WriteIfErr(err, http.StatusUnsupportedMediaType, format, args...) {
+				return true
+			}
+
+			err = fn(upload)
+
+			if i.WriteErr(err, format, args...) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,194 @@
+package httpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strings"
+)
+
+// ErrUploadTypeNotAllowed is returned when an uploaded file is not allowed by the
+// UploadPolicy set with WithUploadPolicy.
+var ErrUploadTypeNotAllowed = errors.New("httpu: upload type not allowed")
+
+// Sniffer returns the content type of a file from its first bytes, or an empty string if it
+// does not recognize the data. data holds at most the first 512 bytes of the file.
+type Sniffer func(data []byte) string
+
+// UploadPolicy restricts the files accepted by TryDecodeJsonFile and ForEachUpload. Each list
+// that is empty is not checked. A file that fails any of the checks is rejected with a HTTP
+// 415, and the reason is logged.
+//
+//	policy := httpu.UploadPolicy{
+//		ContentTypes: []string{"image/*"},
+//		Extensions:   []string{".png", ".jpg", ".jpeg"},
+//		SniffedTypes: []string{"image/png", "image/jpeg"},
+//	}
+type UploadPolicy struct {
+	// ContentTypes are the media types the client may declare in the Content-Type header of
+	// the part holding the file. A type of "image/*" allows any image, and a part with no
+	// Content-Type is treated as application/octet-stream.
+	ContentTypes []string
+
+	// Extensions are the file extensions the name of the file may end with, such as ".json".
+	// Extensions are compared case insensitively.
+	Extensions []string
+
+	// SniffedTypes are the media types the contents of the file may be sniffed as. A type of
+	// "image/*" allows any image.
+	SniffedTypes []string
+
+	// Sniffers are tried in order before the built in sniffing, which recognizes the types
+	// of http.DetectContentType as well as application/json. The first content type
+	// returned is used.
+	Sniffers []Sniffer
+}
+
+// SniffJson returns application/json if data is a json object or array, or the start of
+// one cut off at the end of data, and an empty string otherwise.
+func SniffJson(data []byte) string {
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+
+	if len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return ""
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	for {
+		_, err := decoder.Token()
+
+		switch err {
+		case nil:
+			continue
+		case io.EOF, io.ErrUnexpectedEOF:
+			return "application/json"
+		}
+
+		return ""
+	}
+}
+
+// sniff returns the content type of the data read from r, first trying the sniffers, then
+// http.DetectContentType, and then SniffJson for data detected as plain text. The bytes read
+// from r are returned as well.
+func sniff(r io.Reader, sniffers []Sniffer) (string, []byte, error) {
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, buf)
+
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+
+	buf = buf[:n]
+
+	for _, sniffer := range sniffers {
+		if contentType := sniffer(buf); contentType != "" {
+			return contentType, buf, nil
+		}
+	}
+
+	contentType := http.DetectContentType(buf)
+
+	if strings.HasPrefix(contentType, "text/plain") && SniffJson(buf) != "" {
+		contentType = "application/json"
+	}
+
+	return contentType, buf, nil
+}
+
+// check returns an error wrapping ErrUploadTypeNotAllowed if the file is not allowed by the
+// policy. filename is the name of the file as sent by the client, header the MIME header of
+// its part, and sniffed its sniffed content type. A nil policy allows every file.
+func (up *UploadPolicy) check(filename string, header textproto.MIMEHeader, sniffed string) error {
+	if up == nil {
+		return nil
+	}
+
+	declared := header.Get("Content-Type")
+	if declared == "" {
+		declared = "application/octet-stream"
+	}
+
+	if len(up.ContentTypes) > 0 && matchesMediaType(up.ContentTypes, declared) == false {
+		return fmt.Errorf("%w: declared content type %q of %q", ErrUploadTypeNotAllowed, declared, filename)
+	}
+
+	if len(up.Extensions) > 0 {
+		ext := path.Ext(strings.ReplaceAll(filename, "\\", "/"))
+		allowed := false
+
+		for _, allowedExt := range up.Extensions {
+			if ext != "" && strings.EqualFold(ext, allowedExt) {
+				allowed = true
+				break
+			}
+		}
+
+		if allowed == false {
+			return fmt.Errorf("%w: extension %q of %q", ErrUploadTypeNotAllowed, ext, filename)
+		}
+	}
+
+	if len(up.SniffedTypes) > 0 && matchesMediaType(up.SniffedTypes, sniffed) == false {
+		return fmt.Errorf("%w: sniffed content type %q of %q", ErrUploadTypeNotAllowed, sniffed, filename)
+	}
+
+	return nil
+}
+
+// checkUpload sniffs the content type of the file read from r and checks it against the
+// upload policy. The returned reader reads the whole file, including the bytes sniffed.
+func (up *UploadPolicy) checkUpload(filename string, header textproto.MIMEHeader, r io.Reader) (io.Reader, error) {
+	if up == nil {
+		return r, nil
+	}
+
+	sniffed, head, err := sniff(r, up.Sniffers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return io.MultiReader(bytes.NewReader(head), r), up.check(filename, header, sniffed)
+}
+
+// sniffers returns the sniffers of the policy, which may be nil
+func (up *UploadPolicy) sniffers() []Sniffer {
+	if up == nil {
+		return nil
+	}
+
+	return up.Sniffers
+}
+
+// matchesMediaType returns true if the media type, which may have parameters, matches one of
+// the allowed media types or ranges such as "image/*"
+func matchesMediaType(allowed []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return false
+	}
+
+	mainType := mediaType
+	if index := strings.Index(mediaType, "/"); index >= 0 {
+		mainType = mediaType[:index]
+	}
+
+	for _, allowedType := range allowed {
+		allowedType = strings.ToLower(strings.TrimSpace(allowedType))
+
+		if allowedType == mediaType || allowedType == mainType+"/*" || allowedType == "*/*" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,140 @@
+package httpu_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clavoie/httpu"
+)
+
+func TestSniffJson(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{`{"a": [1, 2]}`, "application/json"},
+		{"\xef\xbb\xbf  [true, null]", "application/json"},
+		{`{"a": "cut off`, "application/json"},
+		{`{a: 1}`, ""},
+		{`[1 2]`, ""},
+		{`"string"`, ""},
+		{``, ""},
+	}
+
+	for _, test := range tests {
+		if actual := httpu.SniffJson([]byte(test.data)); actual != test.expected {
+			t.Fatal("Unexpected sniff", test.data, actual)
+		}
+	}
+}
+
+func TestUploadPolicy(t *testing.T) {
+	type Json struct {
+		Field int
+	}
+
+	jsonFile := multipartFile{field: "data", filename: "data.JSON", contentType: "application/json", content: `{"Field":100}`}
+	policy := &httpu.UploadPolicy{
+		ContentTypes: []string{"application/json", "text/*"},
+		Extensions:   []string{".json"},
+		SniffedTypes: []string{"application/json"},
+	}
+
+	for _, streaming := range []bool{false, true} {
+		opts := []httpu.Option{httpu.WithUploadPolicy(policy), httpu.WithMultipartStreaming(streaming)}
+
+		t.Run("Allowed", func(t *testing.T) {
+			_, w, _, i, finish := newMultipartImpl(t, []multipartFile{jsonFile}, nil, opts...)
+			defer finish()
+
+			j := new(Json)
+			if i.TryDecodeJsonFile("data", j) {
+				t.Fatal("Was not expecting an error", w.Code)
+			}
+
+			if j.Field != 100 {
+				t.Fatal("Unexpected decode", j.Field)
+			}
+		})
+
+		tests := []struct {
+			name string
+			file multipartFile
+		}{
+			{"ContentType", multipartFile{field: "data", filename: "data.json", contentType: "image/png", content: `{"Field":100}`}},
+			{"NoContentType", multipartFile{field: "data", filename: "data.json", content: `{"Field":100}`}},
+			{"Extension", multipartFile{field: "data", filename: "data.exe", contentType: "application/json", content: `{"Field":100}`}},
+			{"Sniffed", multipartFile{field: "data", filename: "data.json", contentType: "application/json", content: "\x89PNG\x0D\x0A\x1A\x0A"}},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, w, l, i, finish := newMultipartImpl(t, []multipartFile{test.file}, nil, opts...)
+				defer finish()
+
+				l.EXPECT().Warningf(NonEmptyStr(), "data", NonEmptyStr())
+
+				if i.TryDecodeJsonFile("data", new(Json)) == false {
+					t.Fatal("Was expecting an error")
+				}
+
+				if w.Code != http.StatusUnsupportedMediaType {
+					t.Fatal("Unexpected code", w.Code)
+				}
+			})
+		}
+	}
+
+	t.Run("Sniffers", func(t *testing.T) {
+		sniffer := func(data []byte) string {
+			if string(data) == "custom" {
+				return "application/x-custom"
+			}
+
+			return ""
+		}
+
+		policy := &httpu.UploadPolicy{SniffedTypes: []string{"application/x-custom"}, Sniffers: []httpu.Sniffer{sniffer}}
+		files := []multipartFile{{field: "data", filename: "data.bin", content: "custom"}}
+		_, _, _, i, finish := newMultipartImpl(t, files, nil, httpu.WithUploadPolicy(policy))
+		defer finish()
+
+		failed := i.ForEachUpload(func(u *httpu.Upload) error {
+			if u.ContentType != "application/x-custom" {
+				t.Fatal("Unexpected content type", u.ContentType)
+			}
+
+			return nil
+		}, "upload")
+
+		if failed {
+			t.Fatal("Was not expecting an error")
+		}
+	})
+
+	t.Run("ForEachUpload", func(t *testing.T) {
+		files := []multipartFile{
+			jsonFile,
+			{field: "other", filename: "other.json", contentType: "application/json", content: "not json"},
+		}
+
+		_, w, l, i, finish := newMultipartImpl(t, files, nil, httpu.WithUploadPolicy(policy))
+		defer finish()
+
+		l.EXPECT().Warningf(NonEmptyStr(), 1, NonEmptyStr())
+		visited := 0
+
+		failed := i.ForEachUpload(func(u *httpu.Upload) error {
+			visited++
+			return nil
+		}, "upload: %v", 1)
+
+		if failed == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusUnsupportedMediaType || visited != 1 {
+			t.Fatal("Unexpected result", w.Code, visited)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package httpu_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/clavoie/erru"
+	"github.com/clavoie/httpu"
+)
+
+func TestForEachUpload(t *testing.T) {
+	format := "upload: %v"
+	png := "\x89PNG\x0D\x0A\x1A\x0A" + "rest of the image"
+	files := []multipartFile{
+		{field: "images", filename: "a.png", contentType: "image/png", content: png},
+		{field: "images", filename: "b.txt", contentType: "image/png", content: "not an image"},
+		{field: "attachments", filename: "c.csv", contentType: "text/csv", content: "a,b\n1,2\n"},
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		_, _, _, i, finish := newMultipartImpl(t, files, map[string]string{"a": "1"}, httpu.WithMultipartLimits(httpu.MultipartLimits{MaxMemory: 1}))
+		defer finish()
+
+		visited := make([]string, 0, 3)
+		var last *httpu.Upload
+
+		failed := i.ForEachUpload(func(u *httpu.Upload) error {
+			buf := new(bytes.Buffer)
+			n, err := u.CopyTo(buf)
+
+			if err != nil || n != u.Size {
+				t.Fatal("Could not copy upload", n, err)
+			}
+
+			visited = append(visited, fmt.Sprintf("%v/%v/%v/%v/%v/%v", u.Field, u.Filename, u.Size, u.ContentType, u.Header.Get("Content-Type"), buf.Len()))
+			last = u
+			return nil
+		}, format, 1)
+
+		if failed {
+			t.Fatal("Was not expecting an error")
+		}
+
+		expected := []string{
+			"attachments/c.csv/8/text/plain; charset=utf-8/text/csv/8",
+			"images/a.png/25/image/png/image/png/25",
+			"images/b.txt/12/text/plain; charset=utf-8/image/png/12",
+		}
+
+		if fmt.Sprint(visited) != fmt.Sprint(expected) {
+			t.Fatal("Unexpected uploads", visited)
+		}
+
+		if file, err := last.Open(); err == nil {
+			file.Close()
+			t.Fatal("Was expecting the temporary files to be removed")
+		}
+	})
+	t.Run("CallbackErr", func(t *testing.T) {
+		_, w, l, i, finish := newMultipartImpl(t, files, nil)
+		defer finish()
+
+		err := erru.NewHttpForbidden("no attachments")
+		l.EXPECT().Warningf(format+": %v", 1, err)
+		visited := 0
+
+		failed := i.ForEachUpload(func(u *httpu.Upload) error {
+			visited++
+			return err
+		}, format, 1)
+
+		if failed == false || visited != 1 || w.Code != http.StatusForbidden {
+			t.Fatal("Was expecting the first error to stop iteration", failed, visited, w.Code)
+		}
+	})
+	t.Run("ParseErr", func(t *testing.T) {
+		r, w, l, i, finish := newMultipartImpl(t, files, nil)
+		defer finish()
+
+		r.Header.Set("Content-Type", "application/json")
+		l.EXPECT().Warningf(format+": %v", 1, http.ErrNotMultipart)
+
+		if i.ForEachUpload(func(u *httpu.Upload) error { return nil }, format, 1) == false || w.Code != http.StatusBadRequest {
+			t.Fatal("Was expecting a 400", w.Code)
+		}
+	})
+}
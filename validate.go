@@ -0,0 +1,317 @@
+package httpu
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator is implemented by types that validate themselves. Validate is called by
+// ValidateStruct after the rules of the validate struct tags have been checked. The error
+// returned is written to the response, so it must be safe to show to clients. Returning
+// ValidationErrs, or a *ValidationErr, reports specific fields.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationErr describes a field that failed validation
+type ValidationErr struct {
+	// Field is the path of the field, made up of json field names, such as "items[2].name".
+	// Field is empty for errors that do not relate to a single field.
+	Field string `json:"field"`
+
+	// Rule is the name of the rule that failed, such as "required" or "max". Errors
+	// returned from Validate that are not ValidationErrs have a rule of "validate".
+	Rule string `json:"rule"`
+
+	// Param is the parameter of the rule, such as "64" for "max=64"
+	Param string `json:"param,omitempty"`
+
+	// Message is an optional description of the failure
+	Message string `json:"message,omitempty"`
+}
+
+func (ve *ValidationErr) Error() string {
+	msg := fmt.Sprintf("field %q failed rule %q", ve.Field, ve.Rule)
+
+	if ve.Param != "" {
+		msg = fmt.Sprintf("field %q failed rule %q with %q", ve.Field, ve.Rule, ve.Param)
+	}
+
+	if ve.Message != "" {
+		msg += ": " + ve.Message
+	}
+
+	return msg
+}
+
+// ValidationErrs is a collection of fields that failed validation
+type ValidationErrs []*ValidationErr
+
+func (ves ValidationErrs) Error() string {
+	msgs := make([]string, len(ves))
+
+	for index, ve := range ves {
+		msgs[index] = ve.Error()
+	}
+
+	return "httpu: validation failed: " + strings.Join(msgs, "; ")
+}
+
+// ValidateStruct checks the fields of v against the rules in their validate struct tags, and
+// then calls Validate on v if it implements Validator. v is usually a pointer to a struct.
+// Nested structs, pointers to structs, and slices of either are validated as well. The rules
+// are separated by commas:
+//
+//	required   the field cannot be its zero value, or a nil pointer, slice, or map
+//	omitempty  the rules that follow are skipped if the field is its zero value
+//	min=N      strings must have at least N characters, slices and maps at least N
+//	           elements, and numbers must be at least N
+//	max=N      strings must have at most N characters, slices and maps at most N
+//	           elements, and numbers must be at most N
+//	email      strings must be a single email address, such as "a@example.com"
+//	oneof=a b  the field must be one of the space separated values
+//
+// Rules other than required are skipped for nil pointers. If any fields fail, ValidationErrs
+// is returned. Any other error, such as an unknown rule, is a mistake in the struct tags.
+func ValidateStruct(v interface{}) error {
+	var errs ValidationErrs
+	err := validateValue(reflect.ValueOf(v), "", &errs)
+
+	if err != nil {
+		return err
+	}
+
+	if validator, isValidator := v.(Validator); isValidator {
+		err = validator.Validate()
+
+		var fieldErrs ValidationErrs
+		var fieldErr *ValidationErr
+
+		switch {
+		case err == nil:
+		case errors.As(err, &fieldErrs):
+			errs = append(errs, fieldErrs...)
+		case errors.As(err, &fieldErr):
+			errs = append(errs, fieldErr)
+		default:
+			errs = append(errs, &ValidationErr{Rule: "validate", Message: err.Error()})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validateValue checks the rules of every field reachable from v, appending the fields that
+// fail to errs
+func validateValue(v reflect.Value, path string, errs *ValidationErrs) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for index := 0; index < v.Len(); index++ {
+			err := validateValue(v.Index(index), fmt.Sprintf("%v[%v]", path, index), errs)
+
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return validateStruct(v, path, errs)
+	}
+
+	return nil
+}
+
+// validateStruct checks the rules of each exported field of the struct v
+func validateStruct(v reflect.Value, path string, errs *ValidationErrs) error {
+	structType := v.Type()
+
+	for index := 0; index < structType.NumField(); index++ {
+		field := structType.Field(index)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, hasName := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		fieldPath := path
+		if hasName || field.Anonymous == false {
+			fieldPath = joinFieldPath(path, name)
+		}
+
+		fieldValue := v.Field(index)
+		err := validateRules(fieldValue, fieldPath, field.Tag.Get("validate"), errs)
+
+		if err != nil {
+			return err
+		}
+
+		err = validateValue(fieldValue, fieldPath, errs)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// jsonFieldName returns the name of the field in json, and whether the name comes from a
+// json struct tag
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+	if name == "" {
+		return field.Name, false
+	}
+
+	return name, true
+}
+
+// joinFieldPath appends name to the field path
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// validateRules checks the value of a field against the rules of its validate struct tag
+func validateRules(v reflect.Value, path string, tag string, errs *ValidationErrs) error {
+	if tag == "" {
+		return nil
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+
+		if index := strings.Index(rule, "="); index >= 0 {
+			name, param = rule[:index], rule[index+1:]
+		}
+
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		switch name {
+		case "required":
+			if v.IsZero() {
+				*errs = append(*errs, &ValidationErr{Field: path, Rule: name})
+				return nil
+			}
+
+			continue
+		case "omitempty":
+			if v.IsZero() {
+				return nil
+			}
+
+			continue
+		}
+
+		value := v
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return nil
+			}
+
+			value = value.Elem()
+		}
+
+		passed, err := checkRule(value, name, param)
+
+		if err != nil {
+			return fmt.Errorf("httpu: validate tag of %v: %w", path, err)
+		}
+
+		if passed == false {
+			*errs = append(*errs, &ValidationErr{Field: path, Rule: name, Param: param})
+		}
+	}
+
+	return nil
+}
+
+// checkRule returns true if v passes the rule. An error is returned if the rule is unknown,
+// its parameter is invalid, or it cannot be applied to the kind of v.
+func checkRule(v reflect.Value, name, param string) (bool, error) {
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+
+		if err != nil {
+			return false, fmt.Errorf("invalid %v parameter %q", name, param)
+		}
+
+		size, err := ruleSize(v)
+
+		if err != nil {
+			return false, err
+		}
+
+		if name == "min" {
+			return size >= limit, nil
+		}
+
+		return size <= limit, nil
+	case "email":
+		if v.Kind() != reflect.String {
+			return false, fmt.Errorf("email cannot be applied to %v", v.Type())
+		}
+
+		address, err := mail.ParseAddress(v.String())
+		return err == nil && address.Name == "" && address.Address == v.String(), nil
+	case "oneof":
+		value := fmt.Sprint(v.Interface())
+
+		for _, option := range strings.Fields(param) {
+			if option == value {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	return false, fmt.Errorf("unknown rule %q", name)
+}
+
+// ruleSize returns the size min and max are compared against: the number of characters in a
+// string, the number of elements in a slice, array, or map, and the value of a number
+func ruleSize(v reflect.Value) (float64, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+
+	return 0, fmt.Errorf("min and max cannot be applied to %v", v.Type())
+}
@@ -0,0 +1,173 @@
+package httpu_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/clavoie/httpu"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required,max=8"`
+}
+
+type validateUser struct {
+	Name      string             `json:"name" validate:"required,min=1,max=8"`
+	Email     string             `json:"email" validate:"omitempty,email"`
+	Role      string             `json:"role" validate:"oneof=admin user"`
+	Age       int                `json:"age" validate:"min=18,max=130"`
+	Nickname  *string            `json:"nickname" validate:"min=2"`
+	Tags      []string           `json:"tags" validate:"max=2"`
+	Home      validateAddress    `json:"home"`
+	Addresses []*validateAddress `json:"addresses" validate:"required"`
+	Ignored   string             `json:"-" validate:"required"`
+	internal  string
+}
+
+func (vu *validateUser) Validate() error {
+	if vu.Role == "admin" && vu.Age < 21 {
+		return &httpu.ValidationErr{Field: "role", Rule: "adminAge", Message: "admins must be 21"}
+	}
+
+	return nil
+}
+
+type validateCustom struct {
+	Value int
+}
+
+func (vc *validateCustom) Validate() error {
+	if vc.Value == 0 {
+		return errors.New("value must be set")
+	}
+
+	return nil
+}
+
+func TestValidateStruct(t *testing.T) {
+	nickname := "x"
+	valid := &validateUser{
+		Name:      "bob",
+		Email:     "bob@example.com",
+		Role:      "user",
+		Age:       30,
+		Home:      validateAddress{"Paris"},
+		Addresses: []*validateAddress{{"Rome"}},
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		if err := httpu.ValidateStruct(valid); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		invalid := &validateUser{
+			Email:     "Bob <bob@example.com>",
+			Role:      "admin",
+			Age:       12,
+			Nickname:  &nickname,
+			Tags:      []string{"a", "b", "c"},
+			Home:      validateAddress{"Llanfairpwllgwyngyll"},
+			Addresses: []*validateAddress{{"Rome"}, {""}},
+		}
+
+		err := httpu.ValidateStruct(invalid)
+
+		var errs httpu.ValidationErrs
+		if errors.As(err, &errs) == false {
+			t.Fatal("Was expecting ValidationErrs", err)
+		}
+
+		expected := httpu.ValidationErrs{
+			{Field: "name", Rule: "required"},
+			{Field: "email", Rule: "email"},
+			{Field: "age", Rule: "min", Param: "18"},
+			{Field: "nickname", Rule: "min", Param: "2"},
+			{Field: "tags", Rule: "max", Param: "2"},
+			{Field: "home.city", Rule: "max", Param: "8"},
+			{Field: "addresses[1].city", Rule: "required"},
+			{Field: "role", Rule: "adminAge", Message: "admins must be 21"},
+		}
+
+		if reflect.DeepEqual(errs, expected) == false {
+			data, _ := json.Marshal(errs)
+			t.Fatal("Unexpected errors", string(data))
+		}
+	})
+	t.Run("Validator", func(t *testing.T) {
+		err := httpu.ValidateStruct(&validateCustom{})
+
+		var errs httpu.ValidationErrs
+		if errors.As(err, &errs) == false || len(errs) != 1 || errs[0].Rule != "validate" || errs[0].Message != "value must be set" {
+			t.Fatal("Unexpected err", err)
+		}
+	})
+	t.Run("InvalidTag", func(t *testing.T) {
+		dst := &struct {
+			Value int `validate:"email"`
+		}{}
+
+		err := httpu.ValidateStruct(dst)
+
+		var errs httpu.ValidationErrs
+		if err == nil || errors.As(err, &errs) {
+			t.Fatal("Was expecting a tag error", err)
+		}
+	})
+}
+
+func TestDecodeAndValidateOr422(t *testing.T) {
+	format := "validate: %v"
+	errFormat := format + ": %v"
+
+	t.Run("Valid", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, `{"name":"bob","role":"user","age":30,"home":{"city":"Paris"},"addresses":[{"city":"Rome"}]}`)
+		defer finish()
+
+		if i.DecodeAndValidateOr422(new(validateUser), format, 1) {
+			t.Fatal("Was not expecting an error", w.Body.String())
+		}
+	})
+	t.Run("DecodeFail", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, `{`)
+		defer finish()
+
+		l.EXPECT().Warningf(errFormat, 1, NonEmptyStr())
+
+		if i.DecodeAndValidateOr422(new(validateUser), format, 1) == false || w.Code != http.StatusBadRequest {
+			t.Fatal("Was expecting a 400", w.Code)
+		}
+	})
+
+	for _, problemDetails := range []bool{false, true} {
+		_, w, l, i, finish := newTestImpl(t, `{"name":"bob","role":"guest","age":30,"home":{"city":"Paris"},"addresses":[{"city":"Rome"}]}`, httpu.WithProblemDetails(problemDetails))
+
+		l.EXPECT().Warningf(errFormat, 1, NonEmptyStr())
+
+		if i.DecodeAndValidateOr422(new(validateUser), format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatal("Unexpected code", w.Code)
+		}
+
+		body := struct {
+			Errors httpu.ValidationErrs `json:"errors"`
+		}{}
+
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := httpu.ValidationErrs{{Field: "role", Rule: "oneof", Param: "admin user"}}
+		if reflect.DeepEqual(body.Errors, expected) == false {
+			t.Fatal("Unexpected body", w.Body.String())
+		}
+
+		finish()
+	}
+}
@@ -9,29 +9,120 @@ import (
 // DecodeJsonOr400 attempts to json decode the request body into the destination object. See
 // encoding/json for details.
 //
-// The request body is closed when this function returns.
+// The request body is closed when this function returns. A body with a Content-Encoding, such
+// as gzip, is decompressed with the Decompressor registered by WithDecompressor. If there is
+// none a HTTP 415 is written to the response, along with an Accept-Encoding header listing
+// the supported codings, and true is returned.
 //
 // If an error is encountered decoding the object a HTTP 400 is written to the response stream,
-// and true is returned. If the decoding succeeds then false is returned
+// and true is returned. If the request body is larger than the limit set by WithMaxBodyBytes
+// with SetDefaultOptions a HTTP 413 is written instead. If the decoding succeeds then false is
+// returned
 func DecodeJsonOr400(w http.ResponseWriter, r *http.Request, dst interface{}, format string, args ...interface{}) bool {
 	return NewImpl(w, r, logu.NewGoLogger()).DecodeJsonOr400(dst, format, args...)
 }
 
+// DecodeOr400 decodes the request body into the destination object using the Decoder
+// registered for the Content-Type of the request. See WithDecoder for the registered
+// media types. Requests without a Content-Type are decoded as json.
+//
+// The request body is closed when this function returns.
+//
+// If no Decoder is registered for the Content-Type a HTTP 415 is written to the response,
+// along with an Accept-Post or Accept-Patch header listing the supported media types, and
+// true is returned. Otherwise DecodeOr400 works like DecodeJsonOr400.
+func DecodeOr400(w http.ResponseWriter, r *http.Request, dst interface{}, format string, args ...interface{}) bool {
+	return NewImpl(w, r, logu.NewGoLogger()).DecodeOr400(dst, format, args...)
+}
+
+// DecodeAndValidateOr422 decodes the request body into the destination object with
+// DecodeOr400, and then validates it with ValidateStruct. See ValidateStruct for the rules
+// of the validate struct tag, and Validator for types that validate themselves.
+//
+// If any fields fail validation a HTTP 422 is written to the response, along with a json
+// body listing the path, rule, and parameter of each failed field, and true is returned.
+// If the validate struct tags themselves are invalid a HTTP 500 is written.
+func DecodeAndValidateOr422(w http.ResponseWriter, r *http.Request, dst interface{}, format string, args ...interface{}) bool {
+	return NewImpl(w, r, logu.NewGoLogger()).DecodeAndValidateOr422(dst, format, args...)
+}
+
+// DecodeJsonStrictOr400 works like DecodeJsonOr400 with strict json decoding turned on.
+// See WithStrictJson for details.
+func DecodeJsonStrictOr400(w http.ResponseWriter, r *http.Request, dst interface{}, format string, args ...interface{}) bool {
+	return NewImplWithOptions(w, r, logu.NewGoLogger(), WithStrictJson(true)).DecodeJsonOr400(dst, format, args...)
+}
+
+// EncodeAcceptedOr500 works like EncodeOr500, writing a HTTP 202 with the Location header set
+// to monitor, if it is not empty. See Impl.EncodeAcceptedOr500 for details.
+func EncodeAcceptedOr500(w http.ResponseWriter, r *http.Request, monitor string, src interface{}, format string, args ...interface{}) bool {
+	return NewImpl(w, r, logu.NewGoLogger()).EncodeAcceptedOr500(monitor, src, format, args...)
+}
+
+// EncodeCreatedOr500 works like EncodeOr500, writing a HTTP 201 with the Location header set
+// to location. See Impl.EncodeCreatedOr500 for details.
+func EncodeCreatedOr500(w http.ResponseWriter, r *http.Request, location string, src interface{}, format string, args ...interface{}) bool {
+	return NewImpl(w, r, logu.NewGoLogger()).EncodeCreatedOr500(location, src, format, args...)
+}
+
+// EncodeOr500 encodes the src object into the response stream using the registered Encoder
+// whose media type is most preferred by the Accept header of the request, including its
+// q-values and wildcards. The Content-Type of the response is set to the media type of the
+// Encoder, and Accept is added to the Vary header. See WithEncoder for the registered
+// media types. If the request has no Accept header the first registered Encoder is used.
+//
+// If none of the registered media types are acceptable a HTTP 406 is written to the
+// response. If there is any error encoding the object a HTTP 500 is written instead.
+//
+// Returns true if there was an error encountered, and false otherwise.
+func EncodeOr500(w http.ResponseWriter, r *http.Request, src interface{}, format string, args ...interface{}) bool {
+	return NewImpl(w, r, logu.NewGoLogger()).EncodeOr500(src, format, args...)
+}
+
 // EncodeJsonOr500 sets the Content-Type of the response to application/json, and encodes the
 // src object into a json response stream. If there is any error encoding the object a
 // HTTP 500 is returned instead.
 //
+// The encoded response is buffered, and written along with its Content-Length only once
+// encoding succeeds. Responses larger than 1MiB are streamed instead, in which case an
+// error part way through can only be logged.
+//
 // Returns true if there was an error encountered, and false otherwise.
 func EncodeJsonOr500(w http.ResponseWriter, src interface{}, format string, args ...interface{}) bool {
 	return NewImpl(w, nil, logu.NewGoLogger()).EncodeJsonOr500(src, format, args...)
 }
 
+// EventStreamOr500 starts a stream of server-sent events in response to the request. See
+// Impl.EventStreamOr500 for details.
+func EventStreamOr500(w http.ResponseWriter, r *http.Request, format string, args ...interface{}) (*EventStream, bool) {
+	return NewImpl(w, r, logu.NewGoLogger()).EventStreamOr500(format, args...)
+}
+
 // SetAsDownloadFileWithName sets the Content-Disposition of the response writer to that of
-// an attachment with the specified file name.
+// an attachment with the specified file name. The file name is escaped as described by
+// ContentDisposition.
 func SetAsDownloadFileWithName(w http.ResponseWriter, filenameFmt string, args ...interface{}) {
 	NewImpl(w, nil, logu.NewGoLogger()).SetAsDownloadFileWithName(filenameFmt, args...)
 }
 
+// SetDispositionWithName sets the Content-Disposition of the response writer to the
+// disposition type, inline or attachment, with the specified file name. The file name is
+// escaped as described by ContentDisposition.
+func SetDispositionWithName(w http.ResponseWriter, disposition Disposition, filenameFmt string, args ...interface{}) {
+	NewImpl(w, nil, logu.NewGoLogger()).SetDispositionWithName(disposition, filenameFmt, args...)
+}
+
+// StreamJsonArrayOr500 streams the items returned from next to the response as the elements
+// of a single json array. See Impl.StreamJsonArrayOr500 for details.
+func StreamJsonArrayOr500(w http.ResponseWriter, r *http.Request, next StreamIterator, format string, args ...interface{}) bool {
+	return NewImpl(w, r, logu.NewGoLogger()).StreamJsonArrayOr500(next, format, args...)
+}
+
+// StreamNdjsonOr500 streams the items returned from next to the response as newline
+// delimited json. See Impl.StreamNdjsonOr500 for details.
+func StreamNdjsonOr500(w http.ResponseWriter, r *http.Request, next StreamIterator, format string, args ...interface{}) bool {
+	return NewImpl(w, r, logu.NewGoLogger()).StreamNdjsonOr500(next, format, args...)
+}
+
 // Write400IfErr works like WriteIfErr(err, http.StatusBadRequest, w, format, args...)
 func Write400IfErr(err error, w http.ResponseWriter, format string, args ...interface{}) bool {
 	return NewImpl(w, nil, logu.NewGoLogger()).WriteIfErr(err, http.StatusBadRequest, format, args...)
@@ -42,11 +133,31 @@ func Write500IfErr(err error, w http.ResponseWriter, format string, args ...inte
 	return NewImpl(w, nil, logu.NewGoLogger()).WriteIfErr(err, http.StatusInternalServerError, format, args...)
 }
 
+// WriteErr works like WriteIfErr, deriving the status code from the error. If an ErrMap
+// is set with SetDefaultOptions and one of its mappings matches the error, its status code
+// is written along with its public message. Otherwise the chain of wrapped errors is
+// searched with errors.As for an error with a StatusCode() int method, such as
+// erru.HttpErr. If there is none, or its status code is not a 4xx or 5xx status,
+// http.StatusInternalServerError is written.
+func WriteErr(err error, w http.ResponseWriter, format string, args ...interface{}) bool {
+	return NewImpl(w, nil, logu.NewGoLogger()).WriteErr(err, format, args...)
+}
+
 // WriteIfErr writes an http status code and message if there is an error. If the error
 // is non-nil then the given http status code is written to the http.ResponseWriter,
-// and the message and error are written to the go log package.
+// and the message and error are written to the go log package. If WithProblemDetails
+// is set with SetDefaultOptions an application/problem+json body describing the status
+// code is written as well. The message and error are never written to the response.
 //
 // true is returned if an error was detected and false is returned if there is no error
 func WriteIfErr(err error, statusCode int, w http.ResponseWriter, format string, args ...interface{}) bool {
 	return NewImpl(w, nil, logu.NewGoLogger()).WriteIfErr(err, statusCode, format, args...)
 }
+
+// WriteProblemIfErr works like WriteIfErr, writing p to the response as an
+// application/problem+json document. The status code written is p.Status, or
+// http.StatusInternalServerError if it is 0. The message and error are written to the log
+// only, keeping them separate from the details sent to the client.
+func WriteProblemIfErr(err error, w http.ResponseWriter, p *Problem, format string, args ...interface{}) bool {
+	return NewImpl(w, nil, logu.NewGoLogger()).WriteProblemIfErr(err, p, format, args...)
+}
@@ -43,6 +43,19 @@ func TestWriteIfErr(t *testing.T) {
 		}
 	})
 
+	t.Run("DecodeJsonStrictOr400", func(t *testing.T) {
+		r, w := newImpl(`{"Field":1,"Extra":2}`, t)
+
+		j := new(Json)
+		if httpu.DecodeJsonStrictOr400(w, r, j, format, formatArgs...) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Unexpected code: %v", w.Code)
+		}
+	})
+
 	t.Run("EncodeJsonOr500", func(t *testing.T) {
 		_, w := newImpl(``, t)
 
@@ -57,6 +70,19 @@ func TestWriteIfErr(t *testing.T) {
 		}
 	})
 
+	t.Run("EncodeOr500", func(t *testing.T) {
+		r, w := newImpl(``, t)
+
+		r.Header.Set("Accept", "application/xml")
+		if httpu.EncodeOr500(w, r, &Json{fieldValue}, format, formatArgs...) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Header().Get("Content-Type") != "application/xml" {
+			t.Fatal("Unexpected content type", w.Header())
+		}
+	})
+
 	t.Run("SetAsDownloadFileWithName", func(t *testing.T) {
 		_, w := newImpl(``, t)
 
@@ -112,8 +138,8 @@ func TestWriteIfErr(t *testing.T) {
 			t.Fatal("Was expecting parse failure")
 		}
 
-		if w.Code != 500 {
-			t.Fatal("Was expecting 500 status code")
+		if w.Code != 400 {
+			t.Fatal("Was expecting 400 status code")
 		}
 	})
 }
@@ -0,0 +1,328 @@
+// Package yaml provides an httpu.Encoder that writes responses as YAML. It is a separate
+// package so that applications which do not need YAML do not have to import it, and it only
+// depends on the standard library:
+//
+//	httpu.NewDiDefs(yaml.WithEncoder())
+//
+// Only encoding is supported. The output is YAML 1.2 in block style, and can be read by any
+// YAML 1.1 or 1.2 parser.
+package yaml
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/clavoie/httpu"
+)
+
+// MediaType is the media type of YAML responses, as registered by RFC 9512
+const MediaType = "application/yaml"
+
+// Encoder is the httpu.Encoder that writes responses as YAML
+var Encoder httpu.Encoder = httpu.EncoderFunc(Encode)
+
+// WithEncoder returns an httpu.Option that registers Encoder for MediaType
+func WithEncoder() httpu.Option {
+	return httpu.WithEncoder(MediaType, Encoder)
+}
+
+// Encode writes src to w as a YAML document. Structs are written as mappings of their
+// fields, which are named by their `yaml:"name"` tag, their `json:"name"` tag, or their field
+// name, in that order. Fields tagged with "-" are skipped, fields tagged with omitempty are
+// skipped when they are empty, and the fields of embedded structs are written as if they
+// were fields of the outer struct. Maps are written with their keys sorted, values that
+// implement encoding.TextMarshaler are written as strings, and []byte is written as
+// !!binary. Strings are quoted whenever they would otherwise be read back as another type.
+func Encode(w io.Writer, src interface{}) error {
+	text, _, err := node(reflect.ValueOf(src), 0)
+
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(text, "\n") == false {
+		text += "\n"
+	}
+
+	_, err = io.WriteString(w, text)
+	return err
+}
+
+// textMarshalerType is the reflect.Type of encoding.TextMarshaler
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// entry is a key and value of a mapping
+type entry struct {
+	key   string
+	value reflect.Value
+}
+
+// node returns the YAML of v. Scalars and empty collections are returned as a single flow
+// value. Non empty mappings and sequences are returned as block lines indented by indent,
+// and isBlock is true.
+func node(v reflect.Value, indent int) (text string, isBlock bool, err error) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return "null", false, nil
+		}
+
+		if v.Type().Implements(textMarshalerType) {
+			break
+		}
+
+		v = v.Elem()
+	}
+
+	if v.IsValid() == false {
+		return "null", false, nil
+	}
+
+	if v.Type().Implements(textMarshalerType) {
+		data, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+
+		if err != nil {
+			return "", false, err
+		}
+
+		return scalar(string(data)), false, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), false, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), false, nil
+	case reflect.Float32, reflect.Float64:
+		return float(v.Float(), v.Type().Bits()), false, nil
+	case reflect.String:
+		return scalar(v.String()), false, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return "null", false, nil
+		}
+
+		return mapping(mapEntries(v), indent)
+	case reflect.Struct:
+		return mapping(structEntries(v, nil), indent)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return "null", false, nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			return "!!binary " + base64.StdEncoding.EncodeToString(data), false, nil
+		}
+
+		return sequence(v, indent)
+	}
+
+	return "", false, fmt.Errorf("yaml: cannot encode %v", v.Type())
+}
+
+// mapping returns the block lines of the entries indented by indent
+func mapping(entries []entry, indent int) (string, bool, error) {
+	if len(entries) == 0 {
+		return "{}", false, nil
+	}
+
+	buf := new(bytes.Buffer)
+	pad := strings.Repeat(" ", indent)
+
+	for _, e := range entries {
+		text, isBlock, err := node(e.value, indent+2)
+
+		if err != nil {
+			return "", false, err
+		}
+
+		if isBlock {
+			buf.WriteString(pad + scalar(e.key) + ":\n" + text)
+		} else {
+			buf.WriteString(pad + scalar(e.key) + ": " + text + "\n")
+		}
+	}
+
+	return buf.String(), true, nil
+}
+
+// sequence returns the block lines of the elements of v indented by indent. The first line
+// of a block element is written on the same line as its "- " indicator.
+func sequence(v reflect.Value, indent int) (string, bool, error) {
+	if v.Len() == 0 {
+		return "[]", false, nil
+	}
+
+	buf := new(bytes.Buffer)
+	pad := strings.Repeat(" ", indent)
+
+	for index := 0; index < v.Len(); index++ {
+		text, isBlock, err := node(v.Index(index), indent+2)
+
+		if err != nil {
+			return "", false, err
+		}
+
+		if isBlock {
+			buf.WriteString(pad + "- " + text[indent+2:])
+		} else {
+			buf.WriteString(pad + "- " + text + "\n")
+		}
+	}
+
+	return buf.String(), true, nil
+}
+
+// mapEntries returns the entries of the map v sorted by key
+func mapEntries(v reflect.Value) []entry {
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+
+	for iter.Next() {
+		key := reflect.Indirect(iter.Key())
+		var name string
+
+		if key.Type().Implements(textMarshalerType) {
+			data, _ := key.Interface().(encoding.TextMarshaler).MarshalText()
+			name = string(data)
+		} else {
+			name = fmt.Sprint(key.Interface())
+		}
+
+		entries = append(entries, entry{name, iter.Value()})
+	}
+
+	sort.Slice(entries, func(a, b int) bool { return entries[a].key < entries[b].key })
+	return entries
+}
+
+// structEntries appends the entries of the fields of the struct v to entries
+func structEntries(v reflect.Value, entries []entry) []entry {
+	structType := v.Type()
+
+	for index := 0; index < structType.NumField(); index++ {
+		field := structType.Field(index)
+		name, omitEmpty, isTagged := fieldName(field)
+
+		if name == "-" && isTagged {
+			continue
+		}
+
+		value := v.Field(index)
+
+		if field.Anonymous && isTagged == false {
+			embedded := value
+
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				entries = structEntries(embedded, entries)
+				continue
+			}
+		}
+
+		if field.IsExported() == false || (omitEmpty && value.IsZero()) {
+			continue
+		}
+
+		if (value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && omitEmpty && value.Len() == 0 {
+			continue
+		}
+
+		entries = append(entries, entry{name, value})
+	}
+
+	return entries
+}
+
+// fieldName returns the name of the field from its yaml or json tag, or its field name if
+// it has neither, and whether it is tagged omitempty
+func fieldName(field reflect.StructField) (name string, omitEmpty bool, isTagged bool) {
+	tag, hasTag := field.Tag.Lookup("yaml")
+
+	if hasTag == false {
+		tag, hasTag = field.Tag.Lookup("json")
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+	omitEmpty = strings.Contains(","+options+",", ",omitempty,")
+
+	if tag == "-" {
+		return "-", false, true
+	}
+
+	if name == "" {
+		return field.Name, omitEmpty, false
+	}
+
+	return name, omitEmpty, hasTag
+}
+
+// float returns the YAML of the float f of the given bit size
+func float(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
+
+// reserved are the plain scalars that YAML 1.1 or 1.2 parsers read as something other than a
+// string
+var reserved = map[string]bool{
+	"~": true, "null": true, "true": true, "false": true, "yes": true, "no": true,
+	"on": true, "off": true, "y": true, "n": true,
+}
+
+// scalar returns s as a plain scalar if it is read back as the same string, and as a double
+// quoted scalar otherwise
+func scalar(s string) string {
+	if isPlain(s) {
+		return s
+	}
+
+	return strconv.Quote(s)
+}
+
+// isPlain returns true if s can be written as a plain scalar. Only strings that start with a
+// letter, and contain no indicators, line breaks, or surrounding space are written plain.
+func isPlain(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || reserved[strings.ToLower(s)] {
+		return false
+	}
+
+	for index, r := range s {
+		if index == 0 && unicode.IsLetter(r) == false {
+			return false
+		}
+
+		if unicode.IsPrint(r) == false || strings.ContainsRune(":#,[]{}&*!|>'\"%@`\\", r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,90 @@
+package yaml_test
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clavoie/httpu"
+	"github.com/clavoie/httpu/yaml"
+	"github.com/clavoie/logu/v2"
+)
+
+func TestEncode(t *testing.T) {
+	type Base struct {
+		Id int
+	}
+
+	type Item struct {
+		Base
+		Name    string `json:"name"`
+		Tags    []string
+		Secret  string `yaml:"-"`
+		Note    string `yaml:"note,omitempty"`
+		Created time.Time
+	}
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		src      interface{}
+		expected string
+	}{
+		{"Nil", nil, "null\n"},
+		{"Scalar", 12, "12\n"},
+		{"Float", math.Inf(-1), "-.inf\n"},
+		{"EmptySlice", []int{}, "[]\n"},
+		{"EmptyMap", map[string]int{}, "{}\n"},
+		{"Bytes", []byte("hi"), "!!binary aGk=\n"},
+		{"Map", map[string]interface{}{"b": 2, "a": []int{1, 2}}, "a:\n  - 1\n  - 2\nb: 2\n"},
+		{"NestedSequence", [][]int{{1, 2}, {3}}, "- - 1\n  - 2\n- - 3\n"},
+		{
+			"Struct",
+			[]*Item{{Base{1}, "one", []string{"a"}, "hidden", "", created}},
+			"- Id: 1\n  name: one\n  Tags:\n    - a\n  Created: \"2024-01-02T03:04:05Z\"\n",
+		},
+		{
+			"Quoting",
+			[]string{"plain text", "", "true", "No", "12", "a: b", "line\nbreak", " padded", "-dash", "it's"},
+			"- plain text\n- \"\"\n- \"true\"\n- \"No\"\n- \"12\"\n- \"a: b\"\n- \"line\\nbreak\"\n- \" padded\"\n- \"-dash\"\n- \"it's\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+
+			if err := yaml.Encode(buf, test.src); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != test.expected {
+				t.Fatalf("Unexpected yaml:\n%v", buf.String())
+			}
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if yaml.Encode(new(bytes.Buffer), make(chan int)) == nil {
+			t.Fatal("Was expecting an error")
+		}
+	})
+}
+
+func TestWithEncoder(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://test.com/items", nil)
+	r.Header.Set("Accept", "application/yaml")
+	w := httptest.NewRecorder()
+	i := httpu.NewImplWithOptions(w, r, logu.NewGoLogger(), yaml.WithEncoder())
+
+	if i.EncodeOr500(map[string]int{"a": 1}, "Could not encode") {
+		t.Fatal("Was not expecting an error")
+	}
+
+	if w.Header().Get("Content-Type") != yaml.MediaType || w.Body.String() != "a: 1\n" {
+		t.Fatal("Unexpected response", w.Header(), w.Body.String())
+	}
+}
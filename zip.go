@@ -0,0 +1,106 @@
+package httpu
+
+import (
+	"archive/zip"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ZipEntry is a file written to a zip archive by ServeZip
+type ZipEntry struct {
+	// Name is the path of the file within the archive. It must be a relative path using
+	// forward slashes, such as "reports/2020.csv".
+	Name string
+
+	// Modified is the modification time of the file
+	Modified time.Time
+
+	// Content is the contents of the file. If Content is also an io.Closer it is closed
+	// once it has been written to the archive. A nil Content writes an empty file.
+	Content io.Reader
+}
+
+// ZipIterator returns the next entry to write to a zip archive, or io.EOF once there are no
+// more entries
+type ZipIterator func() (*ZipEntry, error)
+
+// commitWriter records whether anything has been written to the response
+type commitWriter struct {
+	w         io.Writer
+	committed bool
+}
+
+func (cw *commitWriter) Write(p []byte) (int, error) {
+	cw.committed = true
+	return cw.w.Write(p)
+}
+
+func (i *impl) ServeZip(name string, next ZipIterator, format string, args ...interface{}) bool {
+	header := i.w.Header()
+	header.Set("Content-Type", "application/zip")
+	header.Set("Content-Disposition", ContentDisposition(DispositionAttachment, name))
+
+	cw := &commitWriter{w: i.w}
+	zw := zip.NewWriter(cw)
+	err := writeZipEntries(zw, next)
+
+	if err == nil {
+		err = zw.Close()
+	}
+
+	if err == nil {
+		return false
+	}
+
+	if cw.committed == false {
+		header.Del("Content-Type")
+		header.Del("Content-Disposition")
+		return i.Write500IfErr(err, format, args...)
+	}
+
+	i.logErr(err, http.StatusInternalServerError, format, args...)
+	panic(http.ErrAbortHandler)
+}
+
+// writeZipEntries writes each entry returned from next to the zip archive
+func writeZipEntries(zw *zip.Writer, next ZipIterator) error {
+	for {
+		entry, err := next()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = writeZipEntry(zw, entry)
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeZipEntry writes the entry to the zip archive, compressing it with deflate, and closes
+// its content if it is an io.Closer
+func writeZipEntry(zw *zip.Writer, entry *ZipEntry) error {
+	if closer, isCloser := entry.Content.(io.Closer); isCloser {
+		defer closer.Close()
+	}
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     entry.Name,
+		Method:   zip.Deflate,
+		Modified: entry.Modified,
+	})
+
+	if err != nil || entry.Content == nil {
+		return err
+	}
+
+	_, err = io.Copy(w, entry.Content)
+	return err
+}
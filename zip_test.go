@@ -0,0 +1,117 @@
+package httpu_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clavoie/httpu"
+)
+
+// zipEntries returns a ZipIterator over the entries, returning err once they run out
+func zipEntries(err error, entries ...*httpu.ZipEntry) httpu.ZipIterator {
+	return func() (*httpu.ZipEntry, error) {
+		if len(entries) == 0 {
+			return nil, err
+		}
+
+		entry := entries[0]
+		entries = entries[1:]
+		return entry, nil
+	}
+}
+
+func TestServeZip(t *testing.T) {
+	format := "zip: %v"
+	modtime := time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)
+
+	t.Run("Success", func(t *testing.T) {
+		_, w, _, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		next := zipEntries(io.EOF,
+			&httpu.ZipEntry{Name: "a.txt", Modified: modtime, Content: strings.NewReader("hello")},
+			&httpu.ZipEntry{Name: "dir/b.csv", Modified: modtime, Content: io.NopCloser(strings.NewReader("a,b"))},
+			&httpu.ZipEntry{Name: "empty.txt", Modified: modtime},
+		)
+
+		if i.ServeZip("export.zip", next, format, 1) {
+			t.Fatal("Was not expecting an error")
+		}
+
+		if w.Header().Get("Content-Type") != "application/zip" || w.Header().Get("Content-Disposition") != `attachment; filename="export.zip"` {
+			t.Fatal("Unexpected headers", w.Header())
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := make([]string, 0, len(zr.File))
+		for _, file := range zr.File {
+			rc, err := file.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+
+			if file.Modified.Equal(modtime) == false {
+				t.Fatal("Unexpected modified time", file.Modified)
+			}
+
+			files = append(files, file.Name+"="+string(content))
+		}
+
+		if strings.Join(files, ",") != "a.txt=hello,dir/b.csv=a,b,empty.txt=" {
+			t.Fatal("Unexpected files", files)
+		}
+	})
+
+	t.Run("FailureBeforeCommit", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		l.EXPECT().Errorf(NonEmptyStr(), 1, NonEmptyStr())
+
+		next := zipEntries(errors.New("no entries"), &httpu.ZipEntry{Name: "a.txt", Content: strings.NewReader("hello")})
+		if i.ServeZip("export.zip", next, format, 1) == false {
+			t.Fatal("Was expecting an error")
+		}
+
+		if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Disposition") != "" {
+			t.Fatal("Unexpected response", w.Code, w.Header())
+		}
+	})
+
+	t.Run("FailureAfterCommit", func(t *testing.T) {
+		_, w, l, i, finish := newTestImpl(t, "")
+		defer finish()
+
+		l.EXPECT().Errorf(NonEmptyStr(), 1, NonEmptyStr())
+
+		data := make([]byte, 64*1024)
+		rand.New(rand.NewSource(1)).Read(data)
+		next := zipEntries(errors.New("entry failed"), &httpu.ZipEntry{Name: "a.bin", Content: bytes.NewReader(data)})
+
+		defer func() {
+			if recovered := recover(); recovered != http.ErrAbortHandler {
+				t.Fatal("Was expecting the handler to be aborted", recovered)
+			}
+
+			if w.Code != http.StatusOK || w.Body.Len() == 0 {
+				t.Fatal("Was expecting part of the archive to be written", w.Code)
+			}
+		}()
+
+		i.ServeZip("export.zip", next, format, 1)
+	})
+}